@@ -6,9 +6,8 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/rhosocial/go-rush-common/component/environment"
 )
 
 var Activities *ActivityPool
@@ -17,6 +16,7 @@ var Activities *ActivityPool
 type ActivityPool struct {
 	Activities       map[uint64]*Activity // Save all activity records, the key is the activity ID.
 	ActivitiesRWLock sync.RWMutex         // A lock to access the activity pool.
+	registry         ActivityRegistry     // The registry that activity definitions are written through to, nil if not persisted.
 }
 
 // InitActivityPool initialize an activity pool.
@@ -39,11 +39,23 @@ func (a *ActivityPool) Capacity() int {
 	return len(a.Activities)
 }
 
+// ActivityOption specifies an optional setting of the activity when creating it.
+type ActivityOption func(activity *Activity)
+
+// WithCapacity specifies the number of seats of the activity. 0 means unlimited.
+func WithCapacity(capacity uint64) ActivityOption {
+	return func(activity *Activity) {
+		activity.Capacity = capacity
+	}
+}
+
 // New create an activity.
 // The Activity ID needs to be specified.
 // The newly added activity ID cannot be the same as the existing ID, otherwise an ErrActivityExisted error will be returned.
 // You can specify the target redis server index. If not specified, it will be number 0.
-func (a *ActivityPool) New(id uint64, index *uint8) error {
+// Other settings can be specified by options, see ActivityOption.
+// If the pool has a registry, the activity is saved to it first, and it will not be added if saving fails.
+func (a *ActivityPool) New(id uint64, index *uint8, options ...ActivityOption) error {
 	a.ActivitiesRWLock.Lock()
 	defer a.ActivitiesRWLock.Unlock()
 	if _, existed := a.Activities[id]; existed {
@@ -53,11 +65,52 @@ func (a *ActivityPool) New(id uint64, index *uint8) error {
 	if index != nil {
 		index0 = *index
 	}
-	a.Activities[id] = &Activity{
+	activity := &Activity{
 		ID:               id,
 		RedisServerIndex: index0,
-		Batch:            10000,
+		pool:             a,
+	}
+	for _, option := range options {
+		option(activity)
+	}
+	activity.fillDefaultSettings()
+	if activity.Metadata.CreatedAt.IsZero() {
+		activity.Metadata.CreatedAt = time.Now()
+	}
+	if err := activity.validateSchedule(); err != nil {
+		return err
+	}
+	if err := activity.Mode.Validate(); err != nil {
+		return err
+	}
+	if err := activity.AdaptiveBatch.Validate(); err != nil {
+		return err
 	}
+	if err := activity.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := activity.validateRetry(activity.Retry); err != nil {
+		return err
+	}
+	if err := activity.validatePartitions(); err != nil {
+		return err
+	}
+	if err := activity.validateQuota(activity.Quota); err != nil {
+		return err
+	}
+	if err := activity.validateDryRun(nil); err != nil {
+		return err
+	}
+	if _, err := GetProcessor(activity.Processor); err != nil {
+		return err
+	}
+	if err := activity.validateAllocation(); err != nil {
+		return err
+	}
+	if err := a.save(activity.record()); err != nil {
+		return err
+	}
+	a.Activities[id] = activity
 	return nil
 }
 
@@ -82,7 +135,8 @@ var ErrAllWorkersStopped = errors.New("all workers stopped")
 // If the activity with the specified ID does not exist, an ErrActivityNotExist error will be returning.
 // If the activity is working but does not stop before being removed, an ErrWorkerIsWorking error will be reported.
 // If nil is returning, the removal is successful.
-// TODO: Delete the data located in redis after deleting the corresponding activity.
+// If the pool has a registry, the record is deleted from it too, and the activity will not be removed if deleting fails.
+// The data located in redis are kept, use RemoveAndPurge() to delete them as well.
 func (a *ActivityPool) Remove(id uint64, stopBeforeRemoving bool) error {
 	a.ActivitiesRWLock.Lock()
 	defer a.ActivitiesRWLock.Unlock()
@@ -98,6 +152,17 @@ func (a *ActivityPool) Remove(id uint64, stopBeforeRemoving bool) error {
 		if err != nil {
 			return err
 		}
+	} else {
+		// The activity waiting to be restarted is not regarded as working.
+		_ = activity.Stop(ErrActivityToBeRemoved)
+	}
+	if a.registry != nil {
+		// Detach first, so that the exiting worker will not persist the activity again.
+		activity.attach(nil)
+		if err := a.registry.Delete(context.Background(), id); err != nil {
+			activity.attach(a)
+			return err
+		}
 	}
 	delete(a.Activities, id)
 	return nil
@@ -105,7 +170,7 @@ func (a *ActivityPool) Remove(id uint64, stopBeforeRemoving bool) error {
 
 // RemoveAll removes all activities and return the number of successful removals.
 // If there is an activity in progress, it will be stopped first.
-// TODO: Delete the data located in redis after deleting the corresponding activity.
+// The data located in redis are kept, use RemoveAllAndPurge() to delete them as well.
 func (a *ActivityPool) RemoveAll() int {
 	a.ActivitiesRWLock.Lock()
 	defer a.ActivitiesRWLock.Unlock()
@@ -117,6 +182,14 @@ func (a *ActivityPool) RemoveAll() int {
 			} else {
 				log.Println(err)
 			}
+		} else {
+			_ = v.Stop(ErrActivityToBeRemoved)
+		}
+		if a.registry != nil {
+			v.attach(nil)
+			if err := a.registry.Delete(context.Background(), v.ID); err != nil {
+				log.Println(err)
+			}
 		}
 		delete(a.Activities, v.ID)
 	}
@@ -124,29 +197,58 @@ func (a *ActivityPool) RemoveAll() int {
 }
 
 type ActivityStatus struct {
-	IsWorking        bool  `json:"is_working"`
-	RedisServerIndex uint8 `json:"redis_server_index"`
+	ID               uint64                    `json:"id"`
+	Metadata         ActivityMetadata          `json:"metadata"`
+	IsWorking        bool                      `json:"is_working"`
+	RedisServerIndex uint8                     `json:"redis_server_index"`
+	Batch            uint16                    `json:"batch"`
+	EffectiveBatch   uint16                    `json:"effective_batch"` // The number of applications processed in the next batch.
+	AdaptiveBatch    *ActivityAdaptiveBatch    `json:"adaptive_batch,omitempty"`
+	Interval         uint16                    `json:"interval"`
+	Mode             ActivityMode              `json:"mode"`
+	Capacity         uint64                    `json:"capacity"`
+	Weight           uint16                    `json:"weight"`
+	DryRun           bool                      `json:"dry_run"`
+	Waitlist         bool                      `json:"waitlist"`
+	Quota            uint16                    `json:"quota"`
+	Allocation       ActivityAllocation        `json:"allocation"`
+	Processor        string                    `json:"processor"`
+	StartAt          *time.Time                `json:"start_at,omitempty"`
+	EndAt            *time.Time                `json:"end_at,omitempty"`
+	State            ActivityState             `json:"state"`
+	LastTransition   *ActivityStateTransition  `json:"last_transition,omitempty"` // nil if the activity has never been started.
+	RestartPolicy    *ActivityRestartPolicy    `json:"restart_policy,omitempty"`
+	Retry            *ActivityRetry            `json:"retry,omitempty"`
+	Restarts         uint64                    `json:"restarts"`                  // The total number of restarts by the restart policy.
+	NextRestartAt    *time.Time                `json:"next_restart_at,omitempty"` // nil if no restart is pending.
+	Batches          uint64                    `json:"batches"`                   // The number of batches processed, including the failed ones.
+	LastError        *ActivityError            `json:"last_error,omitempty"`      // nil if there is no error.
+	StopCause        *ActivityStopCause        `json:"stop_cause,omitempty"`      // nil if the worker has never exited.
+	Partitions       []ActivityPartitionStatus `json:"partitions"`                // The progress of each partition, at least one.
+	Counters         ActivityCounters          `json:"counters"`                  // The accumulated results of the successful batches.
 }
 
 // Status returns the status of all activities, such as whether it is working or not,
 // and the index the redis server where the data is located.
 func (a *ActivityPool) Status() map[uint64]ActivityStatus {
+	a.ActivitiesRWLock.RLock()
+	defer a.ActivitiesRWLock.RUnlock()
 	status := make(map[uint64]ActivityStatus)
 	for _, v := range a.Activities {
-		status[v.ID] = ActivityStatus{
-			IsWorking:        v.IsWorking(),
-			RedisServerIndex: v.RedisServerIndex,
-		}
+		status[v.ID] = v.Status()
 	}
 	return status
 }
 
 // StopAll stops the worker coroutines of all activities,
 // and returns the number of activities that were successfully stopped.
+// The desired run state in the registry is kept, so that the workers will be restarted after restoring.
 func (a *ActivityPool) StopAll() int {
 	if a == nil {
 		return 0
 	}
+	a.ActivitiesRWLock.RLock()
+	defer a.ActivitiesRWLock.RUnlock()
 	count := 0
 	for _, v := range a.Activities {
 		err := v.Stop(ErrAllWorkersStopped)
@@ -160,26 +262,119 @@ func (a *ActivityPool) StopAll() int {
 // Activity represents an activity.
 type Activity struct {
 	ID                      uint64
-	RedisServerIndex        uint8                   `json:"redis_server_index" default:"0"` //
-	Batch                   uint16                  `json:"batch" default:"10000"`          // The number of applications processed in each batch.
-	contextCancelFuncRWLock sync.RWMutex            // A lock for manipulating the context cancellation handle.
-	contextCancelFunc       context.CancelCauseFunc // context cancellation handle
+	RedisServerIndex        uint8                            `json:"redis_server_index" default:"0"` //
+	Batch                   uint16                           `json:"batch" default:"1000"`           // The number of applications processed in each batch.
+	Interval                uint16                           `json:"interval" default:"1000"`        // The interval between two batches, in milliseconds.
+	Capacity                uint64                           `json:"capacity" default:"0"`           // The number of seats, 0 means unlimited.
+	Mode                    ActivityMode                     `json:"mode" default:"poll"`            // How the worker waits for applications.
+	AdaptiveBatch           *ActivityAdaptiveBatch           `json:"adaptive_batch,omitempty"`       // How the batch is adjusted, the batch is fixed if nil or the budget is 0.
+	RestartPolicy           *ActivityRestartPolicy           `json:"restart_policy,omitempty"`       // Whether to restart the worker after it exits unexpectedly, nil means never.
+	Retry                   *ActivityRetry                   `json:"retry,omitempty"`                // How the applications whose applicant is missing are retried, nil means never.
+	Weight                  uint16                           `json:"weight" default:"1"`             // The share of the activity when competing with others for a limited redis server.
+	DryRun                  bool                             `json:"dry_run" default:"false"`        // Whether to simulate the batches without writing anything, see WithDryRun().
+	Waitlist                bool                             `json:"waitlist" default:"false"`       // Whether to keep the applicants over capacity in the waitlist, see WithWaitlist().
+	Quota                   uint16                           `json:"quota" default:"1"`              // The maximum number of seats of each applicant, see WithQuota().
+	Allocation              ActivityAllocation               `json:"allocation" default:"fifo"`      // How the seats are allocated, see WithAllocation().
+	Partitions              []ActivityPartition              `json:"partitions,omitempty"`           // The application partitions, each consumed by its own worker coroutine. Empty means a single queue.
+	effectiveBatch          uint16                           // The batch adjusted, guarded by settingsRWLock.
+	StartAt                 *time.Time                       `json:"start_at,omitempty"`   // When the worker is started automatically, nil if not scheduled.
+	EndAt                   *time.Time                       `json:"end_at,omitempty"`     // When the worker is stopped automatically, nil if not scheduled.
+	Metadata                ActivityMetadata                 `json:"metadata"`             // The description of the activity for people.
+	KeyPrefix               *EnvActivityRedisServerKeyPrefix `json:"key_prefix,omitempty"` // The key prefixes, nil means EnvActivityRedisServer.KeyPrefix.
+	Processor               string                           `json:"processor"`            // The name of the processor, see RegisterProcessor().
+	settingsRWLock          sync.RWMutex                     // A lock for the settings that can be changed while working, see ActivitySettings.
+	contextCancelFuncRWLock sync.RWMutex                     // A lock for manipulating the context cancellation handle.
+	contextCancelFunc       context.CancelCauseFunc          // context cancellation handle
+	exited                  <-chan struct{}                  // Closed when the current worker coroutine exits, guarded by contextCancelFuncRWLock.
+	running                 bool                             // The desired run state, which is persisted to the registry.
+	paused                  bool                             // Whether the worker is desired to be paused, which is persisted to the registry.
+	pool                    *ActivityPool                    // The pool that the activity belongs to, guarded by contextCancelFuncRWLock.
+	persisting              *ActivityRecord                  // The record to be written by flush(), guarded by contextCancelFuncRWLock.
+	flushLock               sync.Mutex                       // A lock for writing the records in the order they are built.
+	stateRWLock             sync.RWMutex                     // A lock for manipulating the state.
+	state                   ActivityState                    // The current state, empty means created.
+	stateTransitions        []ActivityStateTransition
+	restartTimer            *time.Timer   // The pending restart, guarded by contextCancelFuncRWLock.
+	nextRestartAt           *time.Time    // When the pending restart happens, guarded by contextCancelFuncRWLock.
+	restartAttempts         uint16        // The consecutive restarts without a successful batch, guarded by contextCancelFuncRWLock.
+	restarts                uint64        // The total number of restarts, guarded by contextCancelFuncRWLock.
+	batches                 atomic.Uint64 // The number of batches processed.
+	errorsRWLock            sync.RWMutex  // A lock for the recent errors and the stop cause.
+	recentErrors            []ActivityError
+	stopCause               *ActivityStopCause
+	partitionsStats         partitionsStats    // The accumulated progress of each partition.
+	stats                   activityStats      // The accumulated results of the batches.
+	dryRun                  activityDryRun     // The state of the simulation in dry-run mode.
+	seatEvents              activitySeatEvents // The recent changes to the seats outside the batches.
+}
+
+// Status returns the status of the activity.
+func (c *Activity) Status() ActivityStatus {
+	status := ActivityStatus{
+		ID:               c.ID,
+		Metadata:         c.Metadata,
+		RedisServerIndex: c.RedisServerIndex,
+		Batch:            c.GetBatch(),
+		EffectiveBatch:   c.GetEffectiveBatch(),
+		AdaptiveBatch:    c.getAdaptiveBatch(),
+		Interval:         uint16(c.GetInterval().Milliseconds()),
+		Mode:             c.GetMode(),
+		Capacity:         c.Capacity,
+		Weight:           c.GetWeight(),
+		DryRun:           c.IsDryRun(),
+		Waitlist:         c.IsWaitlistEnabled(),
+		Quota:            c.GetQuota(),
+		Allocation:       c.Allocation,
+		Processor:        c.Processor,
+		StartAt:          c.StartAt,
+		EndAt:            c.EndAt,
+		State:            c.State(),
+	}
+	status.Batches = c.Batches()
+	status.LastError = c.LastError()
+	status.StopCause = c.StopCause()
+	status.Partitions = c.PartitionProgress()
+	status.Counters = c.Counters()
+	status.RestartPolicy = c.getRestartPolicy()
+	status.Retry = c.getRetry()
+	c.contextCancelFuncRWLock.RLock()
+	status.Restarts = c.restarts
+	status.NextRestartAt = c.nextRestartAt
+	c.contextCancelFuncRWLock.RUnlock()
+	status.IsWorking = status.State.IsWorking()
+	if transitions := c.StateTransitions(); len(transitions) > 0 {
+		status.LastTransition = &transitions[len(transitions)-1]
+	}
+	return status
+}
+
+// WithKeyPrefix specifies the key prefixes of the activity. The empty ones fall back to EnvActivityRedisServer.KeyPrefix.
+func WithKeyPrefix(prefix *EnvActivityRedisServerKeyPrefix) ActivityOption {
+	return func(activity *Activity) {
+		activity.KeyPrefix = prefix
+	}
+}
+
+// GetKeyPrefix returns the key prefixes of the activity, merged with EnvActivityRedisServer.KeyPrefix.
+func (c *Activity) GetKeyPrefix() *EnvActivityRedisServerKeyPrefix {
+	return c.KeyPrefix.Merge((*GlobalEnv).Activity.RedisServer.KeyPrefix)
 }
 
 func (c *Activity) GetRedisServerApplicationKeyName() string {
-	return fmt.Sprintf("%s%d", (*GlobalEnv).Activity.RedisServer.KeyPrefix.Application, c.ID)
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Application, c.ID)
 }
 
 func (c *Activity) GetRedisServerApplicantKeyName() string {
-	return fmt.Sprintf("%s%d", (*GlobalEnv).Activity.RedisServer.KeyPrefix.Applicant, c.ID)
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Applicant, c.ID)
 }
 
 func (c *Activity) GetRedisServerSeatKeyName() string {
-	return fmt.Sprintf("%s%d", (*GlobalEnv).Activity.RedisServer.KeyPrefix.Seat, c.ID)
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Seat, c.ID)
 }
 
 var ErrWorkerHasBeenStopped = errors.New("the worker has already been stopped")
 var ErrWorkerIsWorking = errors.New("the worker is working")
+var ErrWorkerIsDraining = errors.New("the worker is draining")
 
 // ErrWorkerStopped indicates that the worker has stopped.
 var ErrWorkerStopped = errors.New("the worker stopped")
@@ -189,49 +384,50 @@ var ErrWorkerStopped = errors.New("the worker stopped")
 // Returns nil if started successfully.
 // If the redis client is invalid, an ErrRedisClientNil error will be returned.
 // If the corresponding activity has already started the worker coroutine, an ErrWorkerIsWorking error will be returned.
+// If the previous worker coroutine has been stopped but not exited yet, an ErrWorkerIsDraining error will be returned.
+// If the processor of the activity is not registered, an ErrProcessorNotExist error will be returned.
+// The desired run state is persisted as running.
+// The pending restart, if any, is cancelled, and the restart attempts are reset, see RestartPolicy.
 func (c *Activity) Start(ctx context.Context) error {
+	defer c.flush()
 	c.contextCancelFuncRWLock.Lock()
 	defer c.contextCancelFuncRWLock.Unlock()
-	if c.contextCancelFunc != nil {
-		return ErrWorkerIsWorking
+	if err := c.start(ctx); err != nil {
+		return err
 	}
-	ctxChild, cancel := context.WithCancelCause(ctx)
-	c.contextCancelFunc = cancel
-	go worker(ctxChild, 1000, c.ID, processFunc3, nil)
+	c.cancelRestart()
+	c.restartAttempts = 0
 	return nil
 }
 
-// A batch is taken from the application queue and sent into the seat for confirmation.
-//
-// This method relies on the redis function "pop_applications_and_push_into_seats".
-// Therefore, before using this method, you need to prepare relevant redis functions.
-// Since this method is executed by the Lua script of redis,
-// this operation can avoid failure due to changes in related keys,
-// and it will not cause out-of-sequence that may be caused by simultaneous execution of multiple worker processes,
-// and it can also shorten the interaction time with the client.
-var processFunc3 = func(ctx context.Context, activityID uint64) {
-	log.Printf("[ActivityID: %d] working...\n", activityID)
-	activity, err := Activities.GetActivity(activityID)
-	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+// start starts the worker coroutine. The caller must hold contextCancelFuncRWLock.
+func (c *Activity) start(ctx context.Context) error {
+	if c.contextCancelFunc != nil {
+		return ErrWorkerIsWorking
+	}
+	if c.State() == ActivityStateDraining {
+		return ErrWorkerIsDraining
+	}
+	processor, err := GetProcessor(c.Processor)
 	if err != nil {
-		panic(err)
-	}
-	tmStart := time.Now()
-	if val, err := client.FCall(ctx, "pop_applications_and_push_into_seats", []string{
-		activity.GetRedisServerApplicationKeyName(),
-		activity.GetRedisServerApplicantKeyName(),
-		activity.GetRedisServerSeatKeyName(),
-	}, activity.Batch).Uint64Slice(); err == nil {
-		timeElapsed := time.Now().Sub(tmStart)
-		if timeElapsed > time.Minute {
-			timeElapsed = timeElapsed.Truncate(time.Second)
-		}
-		log.Printf("[ActivityID: %d]: %d application(s): %d seat(s) newly confirmed, %d skipped, %d applicant(s) missing, time elapsed : %13v.\n",
-			activityID, val[0], val[1], val[2], val[3], timeElapsed)
-	} else {
-		log.Printf("[ActivityID: %d]: %s\n", activityID, err.Error())
-		panic(err)
+		return err
+	}
+	if err := c.transit(ActivityStateRunning, nil); err != nil {
+		return err
 	}
+	ctxChild, cancel := context.WithCancelCause(ctx)
+	c.contextCancelFunc = cancel
+	c.running = true
+	c.paused = false
+	c.persist()
+	c.resetDryRun()
+	exited := make(chan struct{})
+	c.exited = exited
+	go func() {
+		defer close(exited)
+		worker(ctxChild, c, processor, nil)
+	}()
+	return nil
 }
 
 // Stop a worker coroutine for an activity.
@@ -239,20 +435,91 @@ var processFunc3 = func(ctx context.Context, activityID uint64) {
 // Return nil if stopped successfully.
 // A stop must specify a reason. If it is successfully stopped, ErrWorkerStopped is passed in.
 // If the worker coroutine for the specified activity has stopped, an ErrWorkerHasBeenStopped err will be returned.
+// The activity will be draining until the worker coroutine exits, and then settled according to the cause,
+// see terminalStateOf().
+// Unless the cause is ErrAllWorkersStopped, which means that the process is exiting,
+// the desired run state is persisted as stopped.
+// If the worker coroutine has exited but is waiting to be restarted, the restart is cancelled instead.
 func (c *Activity) Stop(cause error) error {
+	defer c.flush()
 	c.contextCancelFuncRWLock.Lock()
 	defer c.contextCancelFuncRWLock.Unlock()
 	if c.contextCancelFunc == nil {
-		return ErrWorkerHasBeenStopped
+		if !c.cancelRestart() {
+			return ErrWorkerHasBeenStopped
+		}
+		if cause != ErrAllWorkersStopped {
+			c.running = false
+			c.paused = false
+			c.persist()
+		}
+		return nil
+	}
+	if err := c.transit(ActivityStateDraining, cause); err != nil {
+		return err
 	}
 	c.contextCancelFunc(cause)
 	c.contextCancelFunc = nil
+	if cause != ErrAllWorkersStopped {
+		c.running = false
+		c.paused = false
+		c.persist()
+	}
 	return nil
 }
 
-// IsWorking determine whether the current coroutine for activity is working.
+var ErrWorkerIsNotWorking = errors.New("the worker is not working")
+var ErrWorkerIsPaused = errors.New("the worker is paused")
+var ErrWorkerIsNotPaused = errors.New("the worker is not paused")
+
+// Pause a worker coroutine for an activity.
+//
+// The paused worker coroutine keeps alive, as well as its context, but skips processing until it is resumed.
+// If the worker coroutine is not working, an ErrWorkerIsNotWorking error will be returned.
+// If the worker coroutine has been paused, an ErrWorkerIsPaused error will be returned.
+func (c *Activity) Pause() error {
+	defer c.flush()
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	switch c.State() {
+	case ActivityStatePaused:
+		return ErrWorkerIsPaused
+	case ActivityStateRunning:
+	default:
+		return ErrWorkerIsNotWorking
+	}
+	if err := c.transit(ActivityStatePaused, nil); err != nil {
+		return err
+	}
+	c.paused = true
+	c.persist()
+	return nil
+}
+
+// Resume a paused worker coroutine for an activity.
+//
+// If the worker coroutine is not paused, an ErrWorkerIsNotPaused error will be returned.
+func (c *Activity) Resume() error {
+	defer c.flush()
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	if c.State() != ActivityStatePaused {
+		return ErrWorkerIsNotPaused
+	}
+	if err := c.transit(ActivityStateRunning, nil); err != nil {
+		return err
+	}
+	c.paused = false
+	c.persist()
+	return nil
+}
+
+// IsPaused determine whether the current coroutine for activity is paused.
+func (c *Activity) IsPaused() bool {
+	return c.State() == ActivityStatePaused
+}
+
+// IsWorking determine whether the current coroutine for activity is working, i.e. running or paused.
 func (c *Activity) IsWorking() bool {
-	// c.contextCancelFuncRWLock.RLock()
-	// defer c.contextCancelFuncRWLock.RUnlock()
-	return c.contextCancelFunc != nil
+	return c.State().IsWorking()
 }
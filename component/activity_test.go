@@ -2,6 +2,9 @@ package component
 
 import (
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestActivityPool_New(t *testing.T) {
@@ -11,3 +14,38 @@ func TestActivityPool_New(t *testing.T) {
 func TestActivity_IsWorking(t *testing.T) {
 
 }
+
+func TestActivity_Update(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil))
+	assert.Nil(t, Activities.New(2, nil, WithBatch(10), WithInterval(100)))
+
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, defaultActivityBatch(), activity.GetBatch(), "The batch should be the default if not specified.")
+	assert.Equal(t, time.Duration(defaultActivityInterval())*time.Millisecond, activity.GetInterval())
+
+	activity, _ = Activities.GetActivity(2)
+	assert.Equal(t, uint16(10), activity.GetBatch())
+	assert.Equal(t, 100*time.Millisecond, activity.GetInterval())
+
+	batch, interval, zero := uint16(20), uint16(200), uint16(0)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Batch: &batch, Interval: &zero}), ErrActivityIntervalInvalid)
+	assert.Equal(t, uint16(10), activity.GetBatch(), "Nothing should be changed if any setting is invalid.")
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Batch: &zero}), ErrActivityBatchInvalid)
+
+	assert.Nil(t, activity.Update(&ActivitySettings{Interval: &interval}))
+	assert.Equal(t, uint16(10), activity.GetBatch(), "The batch not specified should not be changed.")
+	assert.Equal(t, 200*time.Millisecond, activity.GetInterval())
+	assert.Nil(t, activity.Update(&ActivitySettings{Batch: &batch}))
+	assert.Equal(t, uint16(20), activity.Status().Batch)
+
+	assert.Equal(t, ActivityModePoll, activity.GetMode(), "The mode should be the default if not specified.")
+	mode := ActivityMode("push")
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Mode: &mode}), ErrActivityModeInvalid)
+	mode = ActivityModeBlock
+	assert.Nil(t, activity.Update(&ActivitySettings{Mode: &mode}))
+	assert.Equal(t, ActivityModeBlock, activity.Status().Mode)
+	assert.ErrorIs(t, Activities.New(3, nil, WithMode("push")), ErrActivityModeInvalid)
+}
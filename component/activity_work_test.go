@@ -2,12 +2,19 @@ package component
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	redis2 "github.com/redis/go-redis/v9"
 	"github.com/rhosocial/go-rush-common/component/environment"
 	"github.com/rhosocial/go-rush-common/component/redis"
 	"github.com/stretchr/testify/assert"
@@ -195,3 +202,325 @@ func TestWorking_ConfirmSeatsAfterApplications(t *testing.T) {
 		}
 	})
 }
+
+// TestWorking_BlockMode checks that the worker in block mode confirms the applications as soon as they arrive,
+// rather than after the interval.
+func TestWorking_BlockMode(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+
+	activityID := uint64(time.Now().UnixNano())
+	if err := Activities.New(activityID, nil, WithMode(ActivityModeBlock), WithInterval(5000)); err != nil {
+		t.Error(err)
+		return
+	}
+	defer teardownActivityWorkCase(t, activityID)
+	activity, _ := Activities.GetActivity(activityID)
+	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+	defer client.Del(context.Background(), activity.GetRedisServerDataKeyNames()...)
+
+	if err := activity.Start(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(100 * time.Millisecond) // Wait for the worker to block.
+
+	count := uint16(16)
+	applications := randomStringSlice(&count, "application_")
+	for _, v := range *applications {
+		client.HSet(context.Background(), activity.GetRedisServerApplicantKeyName(), v, "applicant_"+v)
+	}
+	client.RPush(context.Background(), activity.GetRedisServerApplicationKeyName(), *applications)
+	time.Sleep(500 * time.Millisecond) // Much shorter than the interval.
+
+	assert.Equal(t, int64(count), client.ZCard(context.Background(), activity.GetRedisServerSeatKeyName()).Val())
+	assert.Equal(t, int64(0), client.LLen(context.Background(), activity.GetRedisServerApplicationKeyName()).Val())
+	if err := activity.Stop(ErrWorkerStopped); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestWorking_MergeSeats checks merging the seats staged in a remote partition, and merging them again if removing the staged seats failed.
+func TestWorking_MergeSeats(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+	// Both redis servers are the same one, while the partition is still regarded as remote by its index.
+	server := redis.EnvRedisServer{Host: "localhost", Port: 6379}
+	environment.GlobalRedisClientPool.InitRedisClientPool(&[]redis.EnvRedisServer{server, server})
+
+	activityID := uint64(time.Now().UnixNano())
+	if err := Activities.New(activityID, nil, WithCapacity(3), WithPartitions(NewActivityPartitions([]uint8{1}))); err != nil {
+		t.Error(err)
+		return
+	}
+	defer teardownActivityWorkCase(t, activityID)
+	activity, _ := Activities.GetActivity(activityID)
+	assert.True(t, activity.isRemotePartition(0))
+	ctx := context.Background()
+	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+	stagingIndex := uint8(1)
+	staging := environment.GlobalRedisClientPool.GetClient(&stagingIndex)
+	defer staging.Close()
+	seatKey, stagingKey := activity.GetRedisServerSeatKeyName(), activity.GetRedisServerPartitionSeatKeyName(0)
+	defer client.Del(ctx, seatKey, stagingKey)
+
+	stage := func() {
+		staging.ZAdd(ctx, stagingKey,
+			redis2.Z{Score: 100, Member: "a"}, redis2.Z{Score: 200, Member: "b"},
+			redis2.Z{Score: 300, Member: "c"}, redis2.Z{Score: 400, Member: "d"})
+	}
+	client.ZAdd(ctx, seatKey, redis2.Z{Score: 50, Member: "b"})
+	stage()
+	result := BatchResult{Total: 4, Confirmed: 4}
+	assert.Nil(t, activity.mergeSeats(ctx, 0, &result))
+	assert.Equal(t, uint64(2), result.Confirmed)
+	assert.Equal(t, uint64(1), result.Skipped, "The applicant who already has a seat should be skipped.")
+	assert.Equal(t, uint64(1), result.OverCapacity)
+	assert.True(t, result.SoldOut)
+	assert.Equal(t, []redis2.Z{{Score: 50, Member: "b"}, {Score: 100, Member: "a"}, {Score: 300, Member: "c"}},
+		client.ZRangeWithScores(ctx, seatKey, 0, -1).Val(), "The scores should be kept.")
+	assert.Equal(t, int64(0), staging.Exists(ctx, stagingKey).Val(), "The staged seats should be removed after merging.")
+
+	// The staged seats are kept if removing them failed, and merged again in the next batch.
+	stage()
+	result = BatchResult{}
+	assert.Nil(t, activity.mergeSeats(ctx, 0, &result))
+	assert.Equal(t, uint64(0), result.Confirmed)
+	assert.Equal(t, uint64(3), result.Skipped, "The applicants merged last time should be skipped.")
+	assert.Equal(t, uint64(1), result.OverCapacity)
+	assert.Equal(t, int64(3), client.ZCard(ctx, seatKey).Val())
+	assert.Equal(t, float64(300), client.ZScore(ctx, seatKey, "c").Val())
+}
+
+// TestWorking_RevokeSeat checks revoking seats and promoting the applicants in the waitlist into the vacant seats.
+func TestWorking_RevokeSeat(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+	activityID := uint64(time.Now().UnixNano())
+	if err := Activities.New(activityID, nil, WithCapacity(2), WithWaitlist(true)); err != nil {
+		t.Error(err)
+		return
+	}
+	defer teardownActivityWorkCase(t, activityID)
+	activity, _ := Activities.GetActivity(activityID)
+	ctx := context.Background()
+	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+	seatKey, waitlistKey := activity.GetRedisServerSeatKeyName(), activity.GetRedisServerWaitlistKeyName()
+	defer client.Del(ctx, seatKey, waitlistKey, activity.GetRedisServerRevocationKeyName())
+
+	client.ZAdd(ctx, seatKey, redis2.Z{Score: 100, Member: "a"}, redis2.Z{Score: 200, Member: "b"})
+	// b already has a seat, so the earliest applicant to be promoted is c.
+	client.ZAdd(ctx, waitlistKey,
+		redis2.Z{Score: 150, Member: "b"}, redis2.Z{Score: 300, Member: "c"}, redis2.Z{Score: 400, Member: "d"})
+
+	events, err := activity.RevokeSeat(ctx, "a", "fraud")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, ActivitySeatEventPromoted, events[1].Type)
+		assert.Equal(t, "c", events[1].Applicant)
+		assert.Equal(t, "a", events[1].Replaced)
+	}
+	assert.Equal(t, []redis2.Z{{Score: 200, Member: "b"}, {Score: 300, Member: "c"}},
+		client.ZRangeWithScores(ctx, seatKey, 0, -1).Val(), "The promoted applicant should keep the score in the waitlist.")
+	assert.Equal(t, "fraud", client.HGet(ctx, activity.GetRedisServerRevocationKeyName(), "a").Val())
+	assert.Equal(t, []string{"d"}, client.ZRange(ctx, waitlistKey, 0, -1).Val())
+
+	_, err = activity.RevokeSeat(ctx, "a", "fraud")
+	assert.ErrorIs(t, err, ErrApplicantNotSeated)
+
+	events, err = activity.RevokeSeat(ctx, "b", "cancelled")
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	// The waitlist is empty now, so the seat is left vacant.
+	events, err = activity.RevokeSeat(ctx, "c", "cancelled")
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, []string{"d"}, client.ZRange(ctx, seatKey, 0, -1).Val())
+	assert.Equal(t, int64(0), client.Exists(ctx, waitlistKey).Val())
+	assert.Len(t, activity.SeatEvents(), 5)
+
+	// With a quota of 2, the latest seat of the applicant is revoked, and the applicant who has reached the quota is not promoted.
+	quotaActivityID := activityID + 1
+	if err := Activities.New(quotaActivityID, nil, WithCapacity(3), WithWaitlist(true), WithQuota(2)); err != nil {
+		t.Error(err)
+		return
+	}
+	activity, _ = Activities.GetActivity(quotaActivityID)
+	seatKey, waitlistKey, countKey := activity.GetRedisServerSeatKeyName(), activity.GetRedisServerWaitlistKeyName(), activity.GetRedisServerSeatCountKeyName()
+	defer client.Del(ctx, seatKey, waitlistKey, countKey, activity.GetRedisServerRevocationKeyName())
+	client.ZAdd(ctx, seatKey, redis2.Z{Score: 100, Member: "a"}, redis2.Z{Score: 110, Member: "a#2"}, redis2.Z{Score: 200, Member: "b"})
+	client.HSet(ctx, countKey, "a", 2)
+	client.ZAdd(ctx, waitlistKey, redis2.Z{Score: 150, Member: "b"})
+
+	events, err = activity.RevokeSeat(ctx, "a", "fraud")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "b", events[1].Applicant, "The applicant below the quota should be promoted.")
+	}
+	assert.Equal(t, []redis2.Z{{Score: 100, Member: "a"}, {Score: 150, Member: "b#2"}, {Score: 200, Member: "b"}},
+		client.ZRangeWithScores(ctx, seatKey, 0, -1).Val())
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, client.HGetAll(ctx, countKey).Val())
+
+	// b has reached the quota, so c is promoted instead.
+	client.ZAdd(ctx, waitlistKey, redis2.Z{Score: 160, Member: "b"}, redis2.Z{Score: 300, Member: "c"})
+	events, err = activity.RevokeSeat(ctx, "a", "fraud")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "c", events[1].Applicant)
+	}
+	assert.Equal(t, []string{"b#2", "b", "c"}, client.ZRange(ctx, seatKey, 0, -1).Val())
+	assert.Equal(t, map[string]string{"b": "2", "c": "1"}, client.HGetAll(ctx, countKey).Val())
+	_, err = activity.RevokeSeat(ctx, "a", "fraud")
+	assert.ErrorIs(t, err, ErrApplicantNotSeated)
+}
+
+// TestWorking_DeadLetters checks that the dead letters written by the redis function carry the time when they were popped,
+// and can be requeued.
+func TestWorking_DeadLetters(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+
+	activityID := uint64(time.Now().UnixNano())
+	if err := Activities.New(activityID, nil); err != nil {
+		t.Error(err)
+		return
+	}
+	defer teardownActivityWorkCase(t, activityID)
+	activity, _ := Activities.GetActivity(activityID)
+	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+	defer client.Del(context.Background(), activity.GetRedisServerDataKeyNames()...)
+
+	tmStart := time.Now()
+	client.RPush(context.Background(), activity.GetRedisServerApplicationKeyName(), "application_missing")
+	result, err := (&functionProcessor{}).Process(context.Background(), activity.newBatchTask(0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, uint64(1), result.Missing)
+
+	letters, total, err := activity.GetDeadLetters(context.Background(), 0, 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, "application_missing", letters[0].Application)
+		assert.WithinDuration(t, tmStart, letters[0].Time, time.Minute, "The time should be parsed from the entry.")
+	}
+
+	// The entry without a timestamp is taken as the application, like parseDeadLetter().
+	client.RPush(context.Background(), activity.GetRedisServerDeadLetterKeyName(), "application_without_time")
+	requeued, err := activity.RequeueDeadLetters(context.Background(), 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), requeued)
+	assert.Equal(t, []string{"application_missing", "application_without_time"},
+		client.LRange(context.Background(), activity.GetRedisServerApplicationKeyName(), 0, -1).Val())
+	assert.Equal(t, int64(0), client.Exists(context.Background(), activity.GetRedisServerDeadLetterKeyName()).Val())
+}
+
+// TestWorking_Retry checks that the member of the retry key written by the redis function can be parsed,
+// and that the application is placed with the time when it was popped at first.
+func TestWorking_Retry(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+
+	activityID := uint64(time.Now().UnixNano())
+	if err := Activities.New(activityID, nil, WithRetry(&ActivityRetry{Attempts: 1, Delay: 100})); err != nil {
+		t.Error(err)
+		return
+	}
+	defer teardownActivityWorkCase(t, activityID)
+	activity, _ := Activities.GetActivity(activityID)
+	client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+	defer client.Del(context.Background(), activity.GetRedisServerDataKeyNames()...)
+
+	tmStart := time.Now()
+	client.RPush(context.Background(), activity.GetRedisServerApplicationKeyName(), "application_late")
+	result, err := (&functionProcessor{}).Process(context.Background(), activity.newBatchTask(0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, uint64(1), result.Delayed)
+
+	members := client.ZRange(context.Background(), activity.GetRedisServerRetryKeyName(), 0, -1).Val()
+	if !assert.Len(t, members, 1) {
+		return
+	}
+	matches := regexp.MustCompile(`^(\d+):(\d+):(.*)$`).FindStringSubmatch(members[0])
+	if !assert.NotNil(t, matches, "The member should be the integer timestamp, the retries and the application: %s", members[0]) {
+		return
+	}
+	poppedAt, _ := strconv.ParseInt(matches[1], 10, 64)
+	assert.WithinDuration(t, tmStart, time.UnixMicro(poppedAt), time.Minute)
+	assert.Equal(t, "1", matches[2])
+	assert.Equal(t, "application_late", matches[3])
+
+	client.HSet(context.Background(), activity.GetRedisServerApplicantKeyName(), "application_late", "applicant_late")
+	time.Sleep(200 * time.Millisecond)
+	result, err = (&functionProcessor{}).Process(context.Background(), activity.newBatchTask(0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, uint64(1), result.Retried)
+	assert.Equal(t, uint64(1), result.Confirmed)
+	assert.Equal(t, float64(poppedAt), client.ZScore(context.Background(), activity.GetRedisServerSeatKeyName(), "applicant_late").Val())
+}
+
+// TestWorking_Draw checks that the draw is reproducible with the seed, and can be drawn only once.
+func TestWorking_Draw(t *testing.T) {
+	setupActivityWork(t)
+	defer teardownActivityWork(t)
+	ctx := context.Background()
+	applicants := []string{"u1", "u2", "u3", "u4", "u5"}
+	// The applicants are ranked by the SHA1 of the seed and the applicant.
+	ranking := append([]string{}, applicants...)
+	ticket := func(applicant string) string {
+		sum := sha1.Sum([]byte("seed:" + applicant))
+		return hex.EncodeToString(sum[:])
+	}
+	sort.Slice(ranking, func(i, j int) bool { return ticket(ranking[i]) < ticket(ranking[j]) })
+	digest := sha1.Sum([]byte(strings.Join(ranking, "\n")))
+
+	draw := func(activityID uint64) []string {
+		if err := Activities.New(activityID, nil, WithCapacity(2), WithAllocation(ActivityAllocationLottery)); err != nil {
+			t.Error(err)
+			return nil
+		}
+		activity, _ := Activities.GetActivity(activityID)
+		client := environment.GlobalRedisClientPool.GetClient(&activity.RedisServerIndex)
+		seatKey, waitlistKey := activity.GetRedisServerSeatKeyName(), activity.GetRedisServerWaitlistKeyName()
+		registrationKey, drawKey := activity.GetRedisServerRegistrationKeyName(), activity.GetRedisServerDrawKeyName()
+		defer client.Del(ctx, seatKey, waitlistKey, registrationKey, drawKey)
+		for _, applicant := range applicants {
+			client.SAdd(ctx, registrationKey, applicant)
+		}
+
+		result, err := activity.Draw(ctx, "seed")
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		assert.Equal(t, uint64(5), result.Registered)
+		assert.Equal(t, uint64(2), result.Seated)
+		assert.Equal(t, uint64(3), result.Waitlisted)
+		assert.Equal(t, hex.EncodeToString(digest[:]), result.Digest)
+		recorded, err := activity.GetDraw(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, result, recorded)
+
+		_, err = activity.Draw(ctx, "another")
+		assert.ErrorIs(t, err, ErrActivityAlreadyDrawn)
+		seats := client.ZRange(ctx, seatKey, 0, -1).Val()
+		assert.Equal(t, ranking[:2], seats, "The first applicants should fill the seats.")
+		assert.Equal(t, ranking[2:], client.ZRange(ctx, waitlistKey, 0, -1).Val(), "The rest should be waitlisted in the same order.")
+		return append(seats, client.ZRange(ctx, waitlistKey, 0, -1).Val()...)
+	}
+	activityID := uint64(time.Now().UnixNano())
+	first := draw(activityID)
+	assert.Equal(t, first, draw(activityID+1), "The same seed should draw the same ranking.")
+	client := environment.GlobalRedisClientPool.GetClient(nil)
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+}
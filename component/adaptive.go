@@ -0,0 +1,135 @@
+package component
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+var ErrActivityAdaptiveBatchInvalid = errors.New("the adaptive batch must have a budget greater than 0, and 0 < min <= max")
+
+// ActivityAdaptiveBatch specifies how the batch is adjusted to keep each batch within the time budget.
+// The batch starts from Activity.Batch, bounded by Min and Max.
+// A zero Budget means that the batch is fixed.
+type ActivityAdaptiveBatch struct {
+	Budget uint16 `json:"budget" yaml:"Budget"` // The time budget of each batch, in milliseconds.
+	Min    uint16 `json:"min" yaml:"Min"`       // The minimum batch.
+	Max    uint16 `json:"max" yaml:"Max"`       // The maximum batch.
+}
+
+// Enabled determines whether the batch is adaptive.
+func (b *ActivityAdaptiveBatch) Enabled() bool {
+	return b != nil && b.Budget > 0
+}
+
+// Validate checks the bounds if enabled.
+func (b *ActivityAdaptiveBatch) Validate() error {
+	if !b.Enabled() {
+		return nil
+	}
+	if b.Min == 0 || b.Max < b.Min {
+		return ErrActivityAdaptiveBatchInvalid
+	}
+	return nil
+}
+
+// clamp returns the batch bounded by Min and Max.
+func (b *ActivityAdaptiveBatch) clamp(batch uint16) uint16 {
+	if batch < b.Min {
+		return b.Min
+	}
+	if batch > b.Max {
+		return b.Max
+	}
+	return batch
+}
+
+// adaptiveBatchHeadroom is the ratio of the budget that the batch is grown towards,
+// so that the fluctuation of execution time does not exceed the budget frequently.
+const adaptiveBatchHeadroom = 0.8
+
+// next returns the batch after the batch of current size took elapsed to process total applications.
+//
+// If the batch exceeded the budget, the batch is shrunk in proportion to the time over budget.
+// If the batch was full and took less than the headroom of the budget, the batch is grown in proportion,
+// but at most doubled each time. A batch that was not full says nothing about the cost of a full one, so it is kept.
+func (b *ActivityAdaptiveBatch) next(current uint16, total uint64, elapsed time.Duration) uint16 {
+	budget := time.Duration(b.Budget) * time.Millisecond
+	target := float64(current)
+	if elapsed > budget {
+		target = float64(current) * float64(budget) / float64(elapsed)
+	} else if total >= uint64(current) {
+		target = float64(current) * 2
+		if elapsed > 0 {
+			target = math.Min(target, float64(current)*float64(budget)*adaptiveBatchHeadroom/float64(elapsed))
+		}
+		target = math.Max(target, float64(current))
+	}
+	if target > float64(b.Max) {
+		return b.Max
+	}
+	return b.clamp(uint16(target))
+}
+
+// WithAdaptiveBatch specifies how the batch is adjusted.
+// If not specified, EnvActivity.AdaptiveBatch is used. A zero budget means that the batch is fixed regardless of the environment.
+func WithAdaptiveBatch(adaptive *ActivityAdaptiveBatch) ActivityOption {
+	return func(activity *Activity) {
+		activity.AdaptiveBatch = nil
+		if adaptive != nil {
+			copied := *adaptive
+			activity.AdaptiveBatch = &copied
+		}
+	}
+}
+
+// defaultActivityAdaptiveBatch returns a copy of the adaptive batch configured, nil if not configured.
+func defaultActivityAdaptiveBatch() *ActivityAdaptiveBatch {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.AdaptiveBatch.Enabled() {
+		adaptive := *GlobalEnv.Activity.AdaptiveBatch
+		return &adaptive
+	}
+	return nil
+}
+
+// resetEffectiveBatch restarts adjusting from the configured batch. The caller must hold settingsRWLock.
+func (c *Activity) resetEffectiveBatch() {
+	c.effectiveBatch = c.Batch
+	if c.AdaptiveBatch.Enabled() {
+		c.effectiveBatch = c.AdaptiveBatch.clamp(c.Batch)
+	}
+}
+
+// GetEffectiveBatch returns the number of applications processed in the next batch.
+// It is the same as GetBatch() unless the batch is adaptive.
+func (c *Activity) GetEffectiveBatch() uint16 {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.effectiveBatch
+}
+
+// getAdaptiveBatch returns a copy of the adaptive batch, nil if not specified.
+func (c *Activity) getAdaptiveBatch() *ActivityAdaptiveBatch {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	if c.AdaptiveBatch == nil {
+		return nil
+	}
+	adaptive := *c.AdaptiveBatch
+	return &adaptive
+}
+
+// adapt adjusts the effective batch according to the result of the batch of the specified size.
+// The result is ignored if the settings have been changed during the batch.
+func (c *Activity) adapt(batch uint16, result *BatchResult) {
+	c.settingsRWLock.Lock()
+	defer c.settingsRWLock.Unlock()
+	if !c.AdaptiveBatch.Enabled() || c.effectiveBatch != batch {
+		return
+	}
+	c.effectiveBatch = c.AdaptiveBatch.next(batch, result.Total, result.Elapsed)
+	if c.effectiveBatch != batch {
+		log.Printf("[ActivityID: %d] batch adjusted from %d to %d.\n", c.ID, batch, c.effectiveBatch)
+	}
+}
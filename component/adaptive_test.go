@@ -0,0 +1,52 @@
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityAdaptiveBatch_Validate(t *testing.T) {
+	var adaptive *ActivityAdaptiveBatch
+	assert.Nil(t, adaptive.Validate(), "nil means that the batch is fixed.")
+	assert.Nil(t, (&ActivityAdaptiveBatch{}).Validate())
+	assert.Nil(t, (&ActivityAdaptiveBatch{Budget: 10, Min: 1, Max: 1}).Validate())
+	assert.ErrorIs(t, (&ActivityAdaptiveBatch{Budget: 10, Max: 100}).Validate(), ErrActivityAdaptiveBatchInvalid)
+	assert.ErrorIs(t, (&ActivityAdaptiveBatch{Budget: 10, Min: 100, Max: 10}).Validate(), ErrActivityAdaptiveBatchInvalid)
+}
+
+func TestActivityAdaptiveBatch_Next(t *testing.T) {
+	adaptive := ActivityAdaptiveBatch{Budget: 10, Min: 10, Max: 1000}
+
+	assert.Equal(t, uint16(50), adaptive.next(100, 100, 20*time.Millisecond), "The batch over budget should be shrunk in proportion.")
+	assert.Equal(t, uint16(10), adaptive.next(100, 100, time.Second), "The batch should not be less than the minimum.")
+	assert.Equal(t, uint16(200), adaptive.next(100, 100, time.Millisecond), "The batch should be at most doubled.")
+	assert.Equal(t, uint16(160), adaptive.next(100, 100, 5*time.Millisecond), "The batch should be grown towards the headroom.")
+	assert.Equal(t, uint16(100), adaptive.next(100, 100, 9*time.Millisecond), "The batch within the headroom should be kept.")
+	assert.Equal(t, uint16(100), adaptive.next(100, 20, time.Millisecond), "The batch not full should be kept.")
+	assert.Equal(t, uint16(1000), adaptive.next(800, 800, 0), "The batch should not be greater than the maximum.")
+}
+
+func TestActivity_AdaptiveBatch(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithAdaptiveBatch(&ActivityAdaptiveBatch{Budget: 10})), ErrActivityAdaptiveBatchInvalid)
+	assert.Nil(t, Activities.New(1, nil, WithBatch(2000), WithAdaptiveBatch(&ActivityAdaptiveBatch{Budget: 10, Min: 10, Max: 1000})))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, uint16(1000), activity.GetEffectiveBatch(), "The batch should start within the bounds.")
+
+	activity.adapt(1000, &BatchResult{Total: 1000, Elapsed: 20 * time.Millisecond})
+	assert.Equal(t, uint16(500), activity.Status().EffectiveBatch)
+	activity.adapt(1000, &BatchResult{Total: 1000, Elapsed: 20 * time.Millisecond})
+	assert.Equal(t, uint16(500), activity.GetEffectiveBatch(), "The result of a stale batch should be ignored.")
+
+	batch := uint16(100)
+	assert.Nil(t, activity.Update(&ActivitySettings{Batch: &batch}))
+	assert.Equal(t, uint16(100), activity.GetEffectiveBatch(), "Changing the batch should restart adjusting.")
+
+	assert.Nil(t, activity.Update(&ActivitySettings{AdaptiveBatch: &ActivityAdaptiveBatch{}}))
+	activity.adapt(100, &BatchResult{Total: 100, Elapsed: time.Second})
+	assert.Equal(t, uint16(100), activity.GetEffectiveBatch(), "The fixed batch should not be adjusted.")
+}
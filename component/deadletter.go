@@ -0,0 +1,125 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrActivityPartitionNotExist = errors.New("the partition does not exist")
+
+// ActivityDeadLetter represents an application whose applicant was missing when it was popped.
+//
+// The applications whose applicant is missing are moved into the dead-letter list of the partition instead of being dropped,
+// in case the applicant is written a moment after the application. Each entry is the application prefixed with
+// the timestamp in microseconds when it was popped, such as "1700000000000000:application", the earliest comes first.
+type ActivityDeadLetter struct {
+	Application string    `json:"application"`
+	Time        time.Time `json:"time"` // When the application was popped.
+}
+
+// formatDeadLetter returns the entry of the dead-letter list.
+func formatDeadLetter(tm time.Time, application string) string {
+	return strconv.FormatInt(tm.UnixMicro(), 10) + ":" + application
+}
+
+// parseDeadLetter parses the entry of the dead-letter list. The entry without a timestamp is taken as the application.
+func parseDeadLetter(entry string) ActivityDeadLetter {
+	timestamp, application, found := strings.Cut(entry, ":")
+	micro, err := strconv.ParseInt(timestamp, 10, 64)
+	if !found || err != nil {
+		return ActivityDeadLetter{Application: entry}
+	}
+	return ActivityDeadLetter{Application: application, Time: time.UnixMicro(micro)}
+}
+
+func (c *Activity) GetRedisServerDeadLetterKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().DeadLetter, c.ID)
+}
+
+// GetRedisServerPartitionDeadLetterKeyName returns the dead-letter key of the partition,
+// which is located in the redis server of the partition.
+// If the activity is not partitioned, it is the same as GetRedisServerDeadLetterKeyName().
+func (c *Activity) GetRedisServerPartitionDeadLetterKeyName(partition int) string {
+	if len(c.Partitions) == 0 {
+		return c.GetRedisServerDeadLetterKeyName()
+	}
+	return c.GetRedisServerDeadLetterKeyName() + ":" + strconv.Itoa(partition)
+}
+
+// partitionClient returns the client of the redis server where the partition is located.
+// If the partition does not exist, an ErrActivityPartitionNotExist error will be returned.
+func (c *Activity) partitionClient(partition int) (*redis.Client, error) {
+	if partition < 0 || partition >= c.partitionCount() {
+		return nil, ErrActivityPartitionNotExist
+	}
+	index := c.partitionRedisServerIndex(partition)
+	return environment.GlobalRedisClientPool.GetClient(&index), nil
+}
+
+// GetDeadLetters returns the applications in the dead-letter list of the partition from the offset, at most limit of them,
+// and the length of the list. If limit is not greater than 0, all applications from the offset are returned.
+func (c *Activity) GetDeadLetters(ctx context.Context, partition int, offset, limit int64) ([]ActivityDeadLetter, int64, error) {
+	client, err := c.partitionClient(partition)
+	if err != nil {
+		return nil, 0, err
+	}
+	key := c.GetRedisServerPartitionDeadLetterKeyName(partition)
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	entries, err := client.LRange(ctx, key, offset, stop).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := client.LLen(ctx, key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	letters := make([]ActivityDeadLetter, len(entries))
+	for i, entry := range entries {
+		letters[i] = parseDeadLetter(entry)
+	}
+	return letters, total, nil
+}
+
+// RequeueDeadLetters moves the earliest applications in the dead-letter list of the partition back to the front of
+// its application queue, at most count of them, by the redis function "requeue_dead_letters".
+// They keep the order they were popped, and are popped first in the next batch.
+// If count is 0, all applications are requeued. The number of applications requeued is returned.
+func (c *Activity) RequeueDeadLetters(ctx context.Context, partition int, count int64) (int64, error) {
+	client, err := c.partitionClient(partition)
+	if err != nil {
+		return 0, err
+	}
+	return client.FCall(ctx, "requeue_dead_letters", []string{
+		c.GetRedisServerPartitionDeadLetterKeyName(partition),
+		c.GetRedisServerPartitionApplicationKeyName(partition),
+	}, count).Int64()
+}
+
+// PurgeDeadLetters deletes the dead-letter list of the partition, and returns the number of applications deleted.
+func (c *Activity) PurgeDeadLetters(ctx context.Context, partition int) (int64, error) {
+	client, err := c.partitionClient(partition)
+	if err != nil {
+		return 0, err
+	}
+	key := c.GetRedisServerPartitionDeadLetterKeyName(partition)
+	var count *redis.IntCmd
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.LLen(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
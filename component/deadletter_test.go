@@ -0,0 +1,38 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_DeadLetter(t *testing.T) {
+	tm := time.UnixMicro(1700000000123456)
+	assert.Equal(t, "1700000000123456:a:1", formatDeadLetter(tm, "a:1"))
+	assert.Equal(t, ActivityDeadLetter{Application: "a:1", Time: tm}, parseDeadLetter("1700000000123456:a:1"), "The application may contain colons.")
+	assert.Equal(t, ActivityDeadLetter{Application: "a1"}, parseDeadLetter("a1"))
+	assert.Equal(t, ActivityDeadLetter{Application: "x:a1"}, parseDeadLetter("x:a1"))
+}
+
+func TestActivity_DeadLetterKeyNames(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, "activity_dead_letter_1", activity.GetRedisServerDeadLetterKeyName())
+	assert.Equal(t, activity.GetRedisServerDeadLetterKeyName(), activity.newBatchTask(0).DeadLetterKey)
+
+	assert.Nil(t, Activities.New(2, nil, WithPartitions(NewActivityPartitions([]uint8{0, 1}))))
+	activity, _ = Activities.GetActivity(2)
+	assert.Equal(t, "activity_dead_letter_2:1", activity.newBatchTask(1).DeadLetterKey, "Each partition should keep its own dead letters.")
+
+	_, _, err := activity.GetDeadLetters(context.Background(), 2, 0, 10)
+	assert.ErrorIs(t, err, ErrActivityPartitionNotExist)
+	_, err = activity.RequeueDeadLetters(context.Background(), -1, 0)
+	assert.ErrorIs(t, err, ErrActivityPartitionNotExist)
+	_, err = activity.PurgeDeadLetters(context.Background(), 2)
+	assert.ErrorIs(t, err, ErrActivityPartitionNotExist)
+}
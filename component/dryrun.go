@@ -0,0 +1,217 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrActivityDryRunReplyInvalid reports that the reply of the redis function of the simulation is not as expected.
+var ErrActivityDryRunReplyInvalid = errors.New("unexpected reply of the simulation")
+
+// ErrActivityDryRunUnsupported reports that dry-run mode is enabled together with a setting that the simulation does not support.
+var ErrActivityDryRunUnsupported = errors.New("dry-run mode does not support the setting")
+
+// maxActivityDryRunBatches is the number of recent batches kept in the dry-run report.
+const maxActivityDryRunBatches = 32
+
+// ActivityDryRunBatch reports what a batch would do.
+type ActivityDryRunBatch struct {
+	Time      time.Time `json:"time"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"` // The position in the application queue where the batch starts.
+	BatchResult
+}
+
+// ActivityDryRunReport reports what the worker would have done since it started in dry-run mode.
+type ActivityDryRunReport struct {
+	Totals    ActivityCounters      `json:"totals"`
+	Seats     uint64                `json:"seats"`                 // The number of applicants that would be confirmed.
+	SoldOutAt *time.Time            `json:"sold_out_at,omitempty"` // When all seats would have been confirmed, nil if not yet.
+	Offsets   []int64               `json:"offsets"`               // The position in the application queue of each partition where the next batch starts.
+	Batches   []ActivityDryRunBatch `json:"batches"`               // The recent batches, the earliest first.
+}
+
+// activityDryRun keeps the state of the simulation, which is reset every time the worker starts.
+type activityDryRun struct {
+	lock      sync.Mutex
+	offsets   []int64
+	seats     map[string]struct{}
+	soldOutAt *time.Time
+	totals    ActivityCounters
+	batches   []ActivityDryRunBatch
+}
+
+// WithDryRun specifies whether the activity runs in dry-run mode.
+//
+// In dry-run mode, the worker calls the read-only redis function "simulate_pop_applications_and_push_into_seats"
+// with FCALL_RO instead of the processor. The applications are read from where the last batch ended instead of being popped,
+// so the application queues and the seats are left intact, and the results are reported in ActivityDryRunReport
+// instead of the counters of the activity. The worker is not stopped when the seats would have been sold out,
+// and the time is reported in ActivityDryRunReport.SoldOutAt instead.
+//
+// The applicants that would be confirmed are kept in memory, so that they are skipped in later batches.
+// The simulation assumes that nobody else pops the application queues meanwhile.
+// The partitions located in other redis servers are simulated against their own staging keys, which are usually empty,
+// so the applicants who already have a seat are not skipped there.
+// The applications whose applicant is missing are reported in BatchResult.Missing, which would be moved into the dead-letter list.
+// The waitlist, the retries, the quota greater than 1 and the lottery are not simulated,
+// so dry-run mode cannot be enabled together with any of them, see ErrActivityDryRunUnsupported.
+func WithDryRun(dryRun bool) ActivityOption {
+	return func(activity *Activity) {
+		activity.DryRun = dryRun
+	}
+}
+
+// IsDryRun determines whether the activity runs in dry-run mode.
+func (c *Activity) IsDryRun() bool {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.DryRun
+}
+
+// validateDryRun checks that dry-run mode is not enabled together with the settings that the simulation does not support.
+// The specified settings, if any, take precedence over the current ones.
+func (c *Activity) validateDryRun(settings *ActivitySettings) error {
+	c.settingsRWLock.RLock()
+	dryRun, waitlist, retry, quota := c.DryRun, c.Waitlist, c.Retry, c.Quota
+	c.settingsRWLock.RUnlock()
+	if settings != nil {
+		if settings.DryRun != nil {
+			dryRun = *settings.DryRun
+		}
+		if settings.Waitlist != nil {
+			waitlist = *settings.Waitlist
+		}
+		if settings.Retry != nil {
+			retry = settings.Retry
+		}
+		if settings.Quota != nil {
+			quota = *settings.Quota
+		}
+	}
+	if !dryRun {
+		return nil
+	}
+	if waitlist {
+		return fmt.Errorf("%w: waitlist", ErrActivityDryRunUnsupported)
+	}
+	if retry.Enabled() {
+		return fmt.Errorf("%w: retry", ErrActivityDryRunUnsupported)
+	}
+	if quota > 1 {
+		return fmt.Errorf("%w: quota", ErrActivityDryRunUnsupported)
+	}
+	if c.IsLottery() {
+		return fmt.Errorf("%w: lottery", ErrActivityDryRunUnsupported)
+	}
+	return nil
+}
+
+// reset discards the state of the simulation. The caller must hold the lock.
+func (d *activityDryRun) reset(partitions int) {
+	d.offsets = make([]int64, partitions)
+	d.seats = make(map[string]struct{})
+	d.soldOutAt = nil
+	d.totals = ActivityCounters{}
+	d.batches = nil
+}
+
+// resetDryRun discards the state of the simulation.
+func (c *Activity) resetDryRun() {
+	c.dryRun.lock.Lock()
+	defer c.dryRun.lock.Unlock()
+	c.dryRun.reset(c.partitionCount())
+}
+
+// DryRunReport returns what the worker would have done since it started in dry-run mode.
+func (c *Activity) DryRunReport() ActivityDryRunReport {
+	c.dryRun.lock.Lock()
+	defer c.dryRun.lock.Unlock()
+	return ActivityDryRunReport{
+		Totals:    c.dryRun.totals,
+		Seats:     uint64(len(c.dryRun.seats)),
+		SoldOutAt: c.dryRun.soldOutAt,
+		Offsets:   append(make([]int64, 0, c.partitionCount()), c.dryRun.offsets...),
+		Batches:   append(make([]ActivityDryRunBatch, 0, len(c.dryRun.batches)), c.dryRun.batches...),
+	}
+}
+
+// simulate reports what the batch would do without writing anything, see WithDryRun().
+func (c *Activity) simulate(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	tmStart := time.Now()
+	c.dryRun.lock.Lock()
+	if len(c.dryRun.offsets) < c.partitionCount() {
+		c.dryRun.reset(c.partitionCount())
+	}
+	offset := c.dryRun.offsets[task.Partition]
+	simulated := len(c.dryRun.seats)
+	c.dryRun.lock.Unlock()
+
+	val, err := task.client().FCallRo(ctx, "simulate_pop_applications_and_push_into_seats", []string{
+		task.ApplicationKey,
+		task.ApplicantKey,
+		task.SeatKey,
+	}, offset, task.Batch, c.Capacity, simulated).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return c.recordSimulation(task.Partition, offset, val, tmStart)
+}
+
+// recordSimulation parses the reply of the redis function, skips the applicants that would have been confirmed in earlier batches,
+// and accumulates the result into the report. The reply is neither parsed nor recorded if it has fewer than 8 elements.
+func (c *Activity) recordSimulation(partition int, offset int64, val []interface{}, tmStart time.Time) (*BatchResult, error) {
+	if len(val) < 8 {
+		return nil, fmt.Errorf("%w: %d element(s)", ErrActivityDryRunReplyInvalid, len(val))
+	}
+	counts := make([]uint64, 6)
+	for i := range counts {
+		if v, ok := val[i].(int64); ok {
+			counts[i] = uint64(v)
+		}
+	}
+	applicants, _ := val[6].([]interface{})
+	seats, _ := val[7].(int64)
+	result := BatchResult{
+		Total:        counts[0],
+		Confirmed:    counts[1],
+		Skipped:      counts[2],
+		Missing:      counts[3],
+		OverCapacity: counts[4],
+		SoldOut:      counts[5] == 1,
+	}
+
+	c.dryRun.lock.Lock()
+	defer c.dryRun.lock.Unlock()
+	for _, v := range applicants {
+		applicant, _ := v.(string)
+		if _, existed := c.dryRun.seats[applicant]; existed {
+			result.Confirmed--
+			result.Skipped++
+			seats--
+			continue
+		}
+		c.dryRun.seats[applicant] = struct{}{}
+	}
+	result.SoldOut = c.Capacity > 0 && uint64(seats) >= c.Capacity
+	result.Elapsed = time.Since(tmStart)
+	if result.SoldOut && c.dryRun.soldOutAt == nil {
+		now := time.Now()
+		c.dryRun.soldOutAt = &now
+	}
+	c.dryRun.offsets[partition] += int64(result.Total)
+	c.dryRun.totals.add(&result)
+	c.dryRun.batches = append(c.dryRun.batches, ActivityDryRunBatch{
+		Time:        time.Now(),
+		Partition:   partition,
+		Offset:      offset,
+		BatchResult: result,
+	})
+	if len(c.dryRun.batches) > maxActivityDryRunBatches {
+		c.dryRun.batches = c.dryRun.batches[len(c.dryRun.batches)-maxActivityDryRunBatches:]
+	}
+	return &result, nil
+}
@@ -0,0 +1,103 @@
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_RecordSimulation(t *testing.T) {
+	activity := &Activity{ID: 1, Capacity: 4, Partitions: make([]ActivityPartition, 2)}
+	activity.resetDryRun()
+
+	result, err := activity.recordSimulation(0, 0, []interface{}{
+		int64(4), int64(2), int64(1), int64(1), int64(0), int64(0), []interface{}{"u1", "u2"}, int64(3),
+	}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), result.Confirmed)
+	assert.False(t, result.SoldOut)
+	assert.Nil(t, activity.DryRunReport().SoldOutAt)
+
+	// u2 would have been confirmed by partition 0, so it is skipped, and the seats are not sold out yet.
+	result, err = activity.recordSimulation(1, 0, []interface{}{
+		int64(3), int64(2), int64(0), int64(1), int64(0), int64(1), []interface{}{"u2", "u3"}, int64(5),
+	}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), result.Confirmed)
+	assert.Equal(t, uint64(1), result.Skipped)
+	assert.True(t, result.SoldOut)
+
+	report := activity.DryRunReport()
+	assert.NotNil(t, report.SoldOutAt, "The simulated sell-out should be reported.")
+	assert.Equal(t, ActivityCounters{Batches: 2, Total: 7, Confirmed: 3, Skipped: 2, Missing: 2}, report.Totals)
+	assert.Equal(t, uint64(3), report.Seats)
+	assert.Equal(t, []int64{4, 3}, report.Offsets, "The next batch of each partition should start where the last one ended.")
+	assert.Len(t, report.Batches, 2)
+	assert.Equal(t, 1, report.Batches[1].Partition)
+
+	_, err = activity.recordSimulation(0, 0, []interface{}{int64(0)}, time.Now())
+	assert.ErrorIs(t, err, ErrActivityDryRunReplyInvalid)
+	assert.Equal(t, report.Totals, activity.DryRunReport().Totals, "The unexpected reply should not be recorded.")
+
+	for i := 0; i < maxActivityDryRunBatches; i++ {
+		activity.recordSimulation(0, 0, []interface{}{
+			int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), []interface{}{}, int64(3),
+		}, time.Now())
+	}
+	assert.Len(t, activity.DryRunReport().Batches, maxActivityDryRunBatches)
+	assert.Equal(t, report.SoldOutAt, activity.DryRunReport().SoldOutAt, "The time of the first sell-out should be kept.")
+	activity.resetDryRun()
+	assert.Nil(t, activity.DryRunReport().SoldOutAt)
+}
+
+func TestActivity_UpdateDryRun(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil, WithDryRun(true)))
+	activity, _ := Activities.GetActivity(1)
+	assert.True(t, activity.Status().DryRun)
+	activity.resetDryRun()
+	activity.recordSimulation(0, 0, []interface{}{
+		int64(1), int64(1), int64(0), int64(0), int64(0), int64(0), []interface{}{"u1"}, int64(1),
+	}, time.Now())
+
+	dryRun := true
+	assert.Nil(t, activity.Update(&ActivitySettings{DryRun: &dryRun}))
+	assert.Equal(t, uint64(1), activity.DryRunReport().Seats, "The simulation should be kept if not switched.")
+	dryRun = false
+	assert.Nil(t, activity.Update(&ActivitySettings{DryRun: &dryRun}))
+	assert.False(t, activity.IsDryRun())
+	assert.Equal(t, uint64(0), activity.DryRunReport().Seats, "The simulation should be discarded after switching.")
+}
+
+func TestActivity_DryRunUnsupported(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithDryRun(true), WithWaitlist(true)), ErrActivityDryRunUnsupported)
+	_, err := Activities.GetActivity(1)
+	assert.ErrorIs(t, err, ErrActivityNotExist)
+
+	assert.Nil(t, Activities.New(1, nil, WithDryRun(true)))
+	activity, _ := Activities.GetActivity(1)
+	enabled := true
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Waitlist: &enabled}), ErrActivityDryRunUnsupported)
+	assert.False(t, activity.IsWaitlistEnabled(), "Nothing should be changed.")
+
+	disabled := false
+	assert.Nil(t, activity.Update(&ActivitySettings{DryRun: &disabled, Waitlist: &enabled}), "The waitlist can be enabled while switching dry-run mode off.")
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{DryRun: &enabled}), ErrActivityDryRunUnsupported)
+	assert.False(t, activity.IsDryRun())
+	assert.ErrorIs(t, Activities.New(2, nil, WithDryRun(true), WithRetry(&ActivityRetry{Attempts: 1, Delay: 100})), ErrActivityDryRunUnsupported)
+	assert.Nil(t, Activities.New(2, nil, WithDryRun(true), WithRetry(&ActivityRetry{})))
+	activity, _ = Activities.GetActivity(2)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{Attempts: 1, Delay: 100}}), ErrActivityDryRunUnsupported)
+	assert.False(t, activity.getRetry().Enabled(), "Nothing should be changed.")
+
+	assert.ErrorIs(t, Activities.New(3, nil, WithDryRun(true), WithQuota(2)), ErrActivityDryRunUnsupported)
+	quota := uint16(2)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Quota: &quota}), ErrActivityDryRunUnsupported)
+	assert.Equal(t, uint16(1), activity.GetQuota())
+}
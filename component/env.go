@@ -1,6 +1,7 @@
 package component
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strconv"
@@ -12,7 +13,8 @@ import (
 )
 
 type EnvNet struct {
-	ListenPort *uint16 `yaml:"ListenPort,omitempty" default:"8080"`
+	ListenPort      *uint16 `yaml:"ListenPort,omitempty" default:"8080"`
+	ShutdownTimeout *uint16 `yaml:"ShutdownTimeout,omitempty" default:"10"` // 退出时等待请求和工作协程结束的最长时间（秒）。
 }
 
 func (e *EnvNet) GetListenPortDefault() *uint16 {
@@ -20,35 +22,129 @@ func (e *EnvNet) GetListenPortDefault() *uint16 {
 	return &port
 }
 
+func (e *EnvNet) GetShutdownTimeoutDefault() *uint16 {
+	timeout := uint16(10)
+	return &timeout
+}
+
 func (e *EnvNet) Validate() error {
 	if e.ListenPort == nil {
 		e.ListenPort = e.GetListenPortDefault()
 	}
+	if e.ShutdownTimeout == nil {
+		e.ShutdownTimeout = e.GetShutdownTimeoutDefault()
+	}
 	return nil
 }
 
 type EnvActivityRedisServerKeyPrefix struct {
-	Application string `yaml:"Application,omitempty" default:"activity_application_"`
-	Applicant   string `yaml:"Applicant,omitempty" default:"activity_applicant_"`
-	Seat        string `yaml:"Seat,omitempty" default:"activity_seat_"`
+	Application  string `yaml:"Application,omitempty" json:"application,omitempty" default:"activity_application_"`
+	Applicant    string `yaml:"Applicant,omitempty" json:"applicant,omitempty" default:"activity_applicant_"`
+	Seat         string `yaml:"Seat,omitempty" json:"seat,omitempty" default:"activity_seat_"`
+	SeatArchive  string `yaml:"SeatArchive,omitempty" json:"seat_archive,omitempty" default:"activity_seat_archive_"`
+	Waitlist     string `yaml:"Waitlist,omitempty" json:"waitlist,omitempty" default:"activity_waitlist_"`
+	Revocation   string `yaml:"Revocation,omitempty" json:"revocation,omitempty" default:"activity_revocation_"`
+	DeadLetter   string `yaml:"DeadLetter,omitempty" json:"dead_letter,omitempty" default:"activity_dead_letter_"`
+	Retry        string `yaml:"Retry,omitempty" json:"retry,omitempty" default:"activity_retry_"`
+	SeatCount    string `yaml:"SeatCount,omitempty" json:"seat_count,omitempty" default:"activity_seat_count_"`
+	Registration string `yaml:"Registration,omitempty" json:"registration,omitempty" default:"activity_registration_"`
+	Draw         string `yaml:"Draw,omitempty" json:"draw,omitempty" default:"activity_draw_"`
+}
+
+// Merge returns a copy of the key prefixes, and the empty ones are replaced by those of the fallback.
+func (e *EnvActivityRedisServerKeyPrefix) Merge(fallback *EnvActivityRedisServerKeyPrefix) *EnvActivityRedisServerKeyPrefix {
+	merged := *fallback
+	if e == nil {
+		return &merged
+	}
+	if len(e.Application) > 0 {
+		merged.Application = e.Application
+	}
+	if len(e.Applicant) > 0 {
+		merged.Applicant = e.Applicant
+	}
+	if len(e.Seat) > 0 {
+		merged.Seat = e.Seat
+	}
+	if len(e.SeatArchive) > 0 {
+		merged.SeatArchive = e.SeatArchive
+	}
+	if len(e.Waitlist) > 0 {
+		merged.Waitlist = e.Waitlist
+	}
+	if len(e.Revocation) > 0 {
+		merged.Revocation = e.Revocation
+	}
+	if len(e.DeadLetter) > 0 {
+		merged.DeadLetter = e.DeadLetter
+	}
+	if len(e.Retry) > 0 {
+		merged.Retry = e.Retry
+	}
+	if len(e.SeatCount) > 0 {
+		merged.SeatCount = e.SeatCount
+	}
+	if len(e.Registration) > 0 {
+		merged.Registration = e.Registration
+	}
+	if len(e.Draw) > 0 {
+		merged.Draw = e.Draw
+	}
+	return &merged
 }
 
 type EnvActivityRedisServer struct {
 	KeyPrefix *EnvActivityRedisServerKeyPrefix `yaml:"KeyPrefix"`
+	Limit     *RedisServerLimit                `yaml:"Limit,omitempty"`  // The limit of each redis server, nil means unlimited.
+	Limits    map[uint8]RedisServerLimit       `yaml:"Limits,omitempty"` // The limits of specific redis servers, the key is the index, overriding Limit.
+}
+
+// GetLimit returns the limit of the redis server with the index.
+func (e *EnvActivityRedisServer) GetLimit(index uint8) RedisServerLimit {
+	if limit, existed := e.Limits[index]; existed {
+		return limit
+	}
+	if e.Limit != nil {
+		return *e.Limit
+	}
+	return RedisServerLimit{}
 }
 
 func (e *EnvActivityRedisServer) GetKeyPrefixDefault() *EnvActivityRedisServerKeyPrefix {
 	key := EnvActivityRedisServerKeyPrefix{
-		Application: "activity_application_",
-		Applicant:   "activity_applicant_",
-		Seat:        "activity_seat_",
+		Application:  "activity_application_",
+		Applicant:    "activity_applicant_",
+		Seat:         "activity_seat_",
+		SeatArchive:  "activity_seat_archive_",
+		Waitlist:     "activity_waitlist_",
+		Revocation:   "activity_revocation_",
+		DeadLetter:   "activity_dead_letter_",
+		Retry:        "activity_retry_",
+		SeatCount:    "activity_seat_count_",
+		Registration: "activity_registration_",
+		Draw:         "activity_draw_",
 	}
 	return &key
 }
 
+type EnvActivityRegistry struct {
+	RedisServerIndex uint8  `yaml:"RedisServerIndex,omitempty" default:"0"`
+	Key              string `yaml:"Key,omitempty" default:"activity_registry"`
+	TemplateKey      string `yaml:"TemplateKey,omitempty" default:"activity_template_registry"`
+}
+
 type EnvActivity struct {
-	RedisServer *EnvActivityRedisServer `yaml:"RedisServer"`
-	Batch       *uint16                 `yaml:"Batch,omitempty" default:"1000"`
+	RedisServer   *EnvActivityRedisServer `yaml:"RedisServer"`
+	Batch         *uint16                 `yaml:"Batch,omitempty" default:"1000"`
+	Interval      *uint16                 `yaml:"Interval,omitempty" default:"1000"` // The interval between two batches, in milliseconds.
+	Registry      *EnvActivityRegistry    `yaml:"Registry,omitempty"`
+	Processor     *string                 `yaml:"Processor,omitempty" default:"function"` // The name of the default processor.
+	Mode          *ActivityMode           `yaml:"Mode,omitempty" default:"poll"`          // How the worker waits for applications, poll or block.
+	AdaptiveBatch *ActivityAdaptiveBatch  `yaml:"AdaptiveBatch,omitempty"`                // How the batch is adjusted by default, nil means that the batch is fixed.
+	RestartPolicy *ActivityRestartPolicy  `yaml:"RestartPolicy,omitempty"`                // Whether to restart the worker by default, nil means never.
+	Retry         *ActivityRetry          `yaml:"Retry,omitempty"`                        // How the applications whose applicant is missing are retried by default, nil means never.
+	Weight        *uint16                 `yaml:"Weight,omitempty" default:"1"`           // The share of each activity when competing for a limited redis server.
+	Quota         *uint16                 `yaml:"Quota,omitempty" default:"1"`            // The maximum number of seats of each applicant.
 }
 
 func (e *EnvActivity) GetRedisServerDefault() *EnvActivityRedisServer {
@@ -62,6 +158,40 @@ func (e *EnvActivity) GetBatchDefault() *uint16 {
 	return &batch
 }
 
+func (e *EnvActivity) GetIntervalDefault() *uint16 {
+	interval := uint16(1000)
+	return &interval
+}
+
+func (e *EnvActivity) GetModeDefault() *ActivityMode {
+	mode := ActivityModePoll
+	return &mode
+}
+
+func (e *EnvActivity) GetWeightDefault() *uint16 {
+	weight := uint16(1)
+	return &weight
+}
+
+func (e *EnvActivity) GetQuotaDefault() *uint16 {
+	quota := uint16(1)
+	return &quota
+}
+
+func (e *EnvActivity) GetProcessorDefault() *string {
+	processor := ProcessorFunction
+	return &processor
+}
+
+func (e *EnvActivity) GetRegistryDefault() *EnvActivityRegistry {
+	registry := EnvActivityRegistry{
+		RedisServerIndex: 0,
+		Key:              "activity_registry",
+		TemplateKey:      "activity_template_registry",
+	}
+	return &registry
+}
+
 func (e *EnvActivity) Validate() error {
 	if e.RedisServer == nil {
 		e.RedisServer = e.GetRedisServerDefault()
@@ -69,6 +199,48 @@ func (e *EnvActivity) Validate() error {
 	if e.Batch == nil {
 		e.Batch = e.GetBatchDefault()
 	}
+	if e.Interval == nil {
+		e.Interval = e.GetIntervalDefault()
+	}
+	if e.Registry == nil {
+		e.Registry = e.GetRegistryDefault()
+	} else {
+		if len(e.Registry.Key) == 0 {
+			e.Registry.Key = e.GetRegistryDefault().Key
+		}
+		if len(e.Registry.TemplateKey) == 0 {
+			e.Registry.TemplateKey = e.GetRegistryDefault().TemplateKey
+		}
+	}
+	if err := e.AdaptiveBatch.Validate(); err != nil {
+		return err
+	}
+	if err := e.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := e.Retry.Validate(); err != nil {
+		return err
+	}
+	if e.Weight == nil {
+		e.Weight = e.GetWeightDefault()
+	} else if *e.Weight == 0 {
+		return ErrActivityWeightInvalid
+	}
+	if e.Quota == nil {
+		e.Quota = e.GetQuotaDefault()
+	} else if *e.Quota == 0 {
+		return ErrActivityQuotaInvalid
+	}
+	if e.Mode == nil {
+		e.Mode = e.GetModeDefault()
+	} else if err := e.Mode.Validate(); err != nil {
+		return err
+	}
+	if e.Processor == nil {
+		e.Processor = e.GetProcessorDefault()
+	} else if _, err := GetProcessor(*e.Processor); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -81,9 +253,11 @@ type Env struct {
 
 // GetNetDefault 取得 EnvNet 的默认值。
 // EnvNet.ListenPort 默认值为 8080。
+// EnvNet.ShutdownTimeout 默认值为 10。
 func (e *Env) GetNetDefault() *EnvNet {
 	net := EnvNet{}
 	net.ListenPort = net.GetListenPortDefault()
+	net.ShutdownTimeout = net.GetShutdownTimeoutDefault()
 	return &net
 }
 
@@ -97,10 +271,22 @@ func (e *Env) GetRedisServersDefault() *[]componentRedis.EnvRedisServer {
 // GetActivityDefault 取得 EnvActivity 的默认值。
 // EnvActivity.RedisServer 为默认参数，详见 EnvActivity.GetRedisServerDefault()。
 // EnvActivity.Batch 为默认值，详见 EnvActivity.GetBatchDefault()。
+// EnvActivity.Interval 为默认值，详见 EnvActivity.GetIntervalDefault()。
+// EnvActivity.Registry 为默认参数，详见 EnvActivity.GetRegistryDefault()。
+// EnvActivity.Processor 为默认值，详见 EnvActivity.GetProcessorDefault()。
+// EnvActivity.Mode 为默认值，详见 EnvActivity.GetModeDefault()。
+// EnvActivity.Weight 为默认值，详见 EnvActivity.GetWeightDefault()。
+// EnvActivity.Quota 为默认值，详见 EnvActivity.GetQuotaDefault()。
 func (e *Env) GetActivityDefault() *EnvActivity {
 	env := EnvActivity{}
 	env.RedisServer = env.GetRedisServerDefault()
 	env.Batch = env.GetBatchDefault()
+	env.Interval = env.GetIntervalDefault()
+	env.Registry = env.GetRegistryDefault()
+	env.Processor = env.GetProcessorDefault()
+	env.Mode = env.GetModeDefault()
+	env.Weight = env.GetWeightDefault()
+	env.Quota = env.GetQuotaDefault()
 	return &env
 }
 
@@ -133,6 +319,21 @@ func (e *Env) Validate() error {
 
 var GlobalEnv *Env
 
+// CloseRedisClients 关闭所有已配置的 redis 服务器的客户端，返回关闭时遇到的全部错误。
+func CloseRedisClients() error {
+	if GlobalEnv == nil || GlobalEnv.RedisServers == nil || environment.GlobalRedisClientPool == nil {
+		return nil
+	}
+	var errs []error
+	for i := range *GlobalEnv.RedisServers {
+		index := uint8(i)
+		if err := environment.GlobalRedisClientPool.GetClient(&index).Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // LoadEnvDefault 加载配置参数默认值。
 func LoadEnvDefault() error {
 	if GlobalEnv == nil {
@@ -198,10 +399,51 @@ func LoadEnvFromSystemEnvVar() error {
 		port, _ := strconv.ParseUint(value, 10, 16)
 		*(*GlobalEnv.Net).ListenPort = uint16(port)
 	}
+	if value, exist := os.LookupEnv("Consumer_Net_ShutdownTimeout"); exist {
+		log.Println("Consumer_Net_ShutdownTimeout: ", value)
+		timeout, _ := strconv.ParseUint(value, 10, 16)
+		*(*GlobalEnv.Net).ShutdownTimeout = uint16(timeout)
+	}
 	if value, exist := os.LookupEnv("Consumer_Activity_Batch"); exist {
 		log.Println("Consumer_Activity_Batch: ", value)
 		batch, _ := strconv.ParseUint(value, 10, 8)
 		*(*GlobalEnv.Activity).Batch = uint16(batch)
 	}
+	if value, exist := os.LookupEnv("Consumer_Activity_Interval"); exist {
+		log.Println("Consumer_Activity_Interval: ", value)
+		interval, _ := strconv.ParseUint(value, 10, 16)
+		*(*GlobalEnv.Activity).Interval = uint16(interval)
+	}
+	if value, exist := os.LookupEnv("Consumer_Activity_Processor"); exist {
+		log.Println("Consumer_Activity_Processor: ", value)
+		if _, err := GetProcessor(value); err != nil {
+			return err
+		}
+		*(*GlobalEnv.Activity).Processor = value
+	}
+	if value, exist := os.LookupEnv("Consumer_Activity_Mode"); exist {
+		log.Println("Consumer_Activity_Mode: ", value)
+		mode := ActivityMode(value)
+		if err := mode.Validate(); err != nil {
+			return err
+		}
+		*(*GlobalEnv.Activity).Mode = mode
+	}
+	if value, exist := os.LookupEnv("Consumer_Activity_Weight"); exist {
+		log.Println("Consumer_Activity_Weight: ", value)
+		weight, _ := strconv.ParseUint(value, 10, 16)
+		if weight == 0 {
+			return ErrActivityWeightInvalid
+		}
+		*(*GlobalEnv.Activity).Weight = uint16(weight)
+	}
+	if value, exist := os.LookupEnv("Consumer_Activity_Quota"); exist {
+		log.Println("Consumer_Activity_Quota: ", value)
+		quota, _ := strconv.ParseUint(value, 10, 16)
+		if quota == 0 {
+			return ErrActivityQuotaInvalid
+		}
+		*(*GlobalEnv.Activity).Quota = uint16(quota)
+	}
 	return nil
 }
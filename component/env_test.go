@@ -91,6 +91,7 @@ func TestLoadEnvFromYaml_EmptyContent(t *testing.T) {
 		assert.NotNil(t, (*GlobalEnv).Net, "The `Net` attribute of `GlobalEnv` should not be `nil`.")
 		assert.NotNil(t, (*(*GlobalEnv).Net).ListenPort, "The `ListenPort` attribute of `Net` should not be `nil`.")
 		assert.Equal(t, uint16(8080), *(*(*GlobalEnv).Net).ListenPort, "The default port is `8080` when not defined.")
+		assert.Equal(t, uint16(10), *(*(*GlobalEnv).Net).ShutdownTimeout, "The default shutdown timeout is `10` when not defined.")
 	})
 
 	t.Run("RedisServer", func(t *testing.T) {
@@ -109,6 +110,7 @@ func TestLoadEnvFromYaml_EmptyContent(t *testing.T) {
 		}
 		assert.NotNil(t, (*GlobalEnv).Activity, "The `Activity` attribute of `GlobalEnv` should not be `nil`.")
 		assert.Equal(t, uint16(1000), *(*(*GlobalEnv).Activity).Batch, "The default batch is `100` when not defined.")
+		assert.Equal(t, uint16(1000), *(*(*GlobalEnv).Activity).Interval, "The default interval is `1000` when not defined.")
 	})
 }
 
@@ -0,0 +1,88 @@
+package component
+
+import (
+	"time"
+)
+
+// maxActivityErrors is the number of recent errors kept for each activity.
+const maxActivityErrors = 32
+
+// ActivityError represents an error that occurred while the worker was working.
+type ActivityError struct {
+	Time  time.Time `json:"time"`
+	Cause string    `json:"cause"`
+	Batch uint64    `json:"batch"` // The sequence number of the batch, starting from 1. 0 means that the error occurred before any batch.
+}
+
+// ActivityStopCause represents why the worker exited last time, which is the cause of its context.
+type ActivityStopCause struct {
+	Time  time.Time `json:"time"`
+	Cause string    `json:"cause"`
+}
+
+// recordError appends the error to the ring of recent errors. The earliest error is discarded if the ring is full.
+func (c *Activity) recordError(err error, batch uint64) {
+	if err == nil {
+		return
+	}
+	c.errorsRWLock.Lock()
+	defer c.errorsRWLock.Unlock()
+	record := ActivityError{
+		Time:  time.Now(),
+		Cause: err.Error(),
+		Batch: batch,
+	}
+	if len(c.recentErrors) < maxActivityErrors {
+		c.recentErrors = append(c.recentErrors, record)
+		return
+	}
+	copy(c.recentErrors, c.recentErrors[1:])
+	c.recentErrors[len(c.recentErrors)-1] = record
+}
+
+// recordStopCause records why the worker exited.
+func (c *Activity) recordStopCause(cause error) {
+	record := ActivityStopCause{Time: time.Now()}
+	if cause != nil {
+		record.Cause = cause.Error()
+	}
+	c.errorsRWLock.Lock()
+	defer c.errorsRWLock.Unlock()
+	c.stopCause = &record
+}
+
+// Errors returns the recent errors of the activity, the earliest comes first.
+func (c *Activity) Errors() []ActivityError {
+	c.errorsRWLock.RLock()
+	defer c.errorsRWLock.RUnlock()
+	errs := make([]ActivityError, len(c.recentErrors))
+	copy(errs, c.recentErrors)
+	return errs
+}
+
+// LastError returns the latest error of the activity, nil if there is no error.
+func (c *Activity) LastError() *ActivityError {
+	c.errorsRWLock.RLock()
+	defer c.errorsRWLock.RUnlock()
+	if len(c.recentErrors) == 0 {
+		return nil
+	}
+	last := c.recentErrors[len(c.recentErrors)-1]
+	return &last
+}
+
+// StopCause returns why the worker exited last time, nil if it has never exited.
+func (c *Activity) StopCause() *ActivityStopCause {
+	c.errorsRWLock.RLock()
+	defer c.errorsRWLock.RUnlock()
+	if c.stopCause == nil {
+		return nil
+	}
+	cause := *c.stopCause
+	return &cause
+}
+
+// Batches returns the number of batches that have been processed, including the failed ones.
+func (c *Activity) Batches() uint64 {
+	return c.batches.Load()
+}
@@ -0,0 +1,65 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_RecordError(t *testing.T) {
+	activity := Activity{ID: 1}
+	assert.Nil(t, activity.LastError())
+	assert.Empty(t, activity.Errors())
+
+	activity.recordError(nil, 1)
+	assert.Empty(t, activity.Errors(), "nil should not be recorded.")
+	for i := 1; i <= maxActivityErrors+2; i++ {
+		activity.recordError(fmt.Errorf("error %d", i), uint64(i))
+	}
+	errs := activity.Errors()
+	assert.Len(t, errs, maxActivityErrors, "The ring should be bounded.")
+	assert.Equal(t, "error 3", errs[0].Cause, "The earliest errors should be discarded.")
+	assert.Equal(t, uint64(3), errs[0].Batch)
+	assert.Equal(t, fmt.Sprintf("error %d", maxActivityErrors+2), activity.LastError().Cause)
+}
+
+// TestWorker_Errors 测试处理器返回错误时记录错误和停止原因。
+func TestWorker_Errors(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	RegisterProcessor("third-fails", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		if task.Activity.Batches() == 3 {
+			return nil, errors.New("timeout")
+		}
+		return &BatchResult{}, nil
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "third-fails")
+		processorsRWLock.Unlock()
+	}()
+
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("third-fails"), WithInterval(1)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.StopCause())
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(50 * time.Millisecond)
+
+	status := activity.Status()
+	assert.Equal(t, ActivityStateFailed, status.State)
+	assert.Equal(t, uint64(3), status.Batches)
+	assert.Equal(t, "timeout", status.LastError.Cause)
+	assert.Equal(t, uint64(3), status.LastError.Batch)
+	assert.Equal(t, "timeout", status.StopCause.Cause, "The stop cause should be the cause of the context.")
+
+	assert.Nil(t, activity.Start(context.Background()))
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, ErrWorkerStopped.Error(), activity.StopCause().Cause)
+	assert.Len(t, activity.Errors(), 1, "Stopping on purpose is not an error.")
+}
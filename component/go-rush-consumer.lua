@@ -21,12 +21,69 @@ local function get_applicant_by_application(key, application)
     return redis.call("HGET", key, application)
 end
 
-local function push_applicant_into_seats(key, applicant)
-    return redis.call("ZADD", key, "NX", get_timestamp_micro(), applicant)
+local function push_applicant_into_seats(key, applicant, score)
+    return redis.call("ZADD", key, "NX", score, applicant)
+end
+
+local function push_applicant_into_waitlist(key, applicant, score)
+    return redis.call("ZADD", key, "NX", score, applicant)
+end
+
+-- Format the timestamp as an integer.
+-- Concatenating the number directly yields something like "1.7e+15", which loses the precision.
+local function format_timestamp(timestamp)
+    return string.format("%d", timestamp)
+end
+
+-- The entry of the dead-letter list is the application prefixed with the timestamp in microseconds when it was popped.
+local function push_application_into_dead_letters(key, application, popped_at)
+    return redis.call("RPUSH", key, format_timestamp(popped_at) .. ":" .. application)
+end
+
+-- Parse the application from the entry of the dead-letter list. The entry without a timestamp is taken as the application.
+local function parse_dead_letter(entry)
+    return string.match(entry, "^[+-]?%d+:(.*)$") or entry
+end
+
+-- The member of the retry key is the application prefixed with the timestamp when it was popped and the number of retries,
+-- and the score is when it is retried.
+local function push_application_into_retries(key, application, popped_at, retries, delay)
+    return redis.call("ZADD", key, get_timestamp_micro() + delay * 1000,
+            format_timestamp(popped_at) .. ":" .. retries .. ":" .. application)
+end
+
+-- The first seat of the applicant is the applicant itself, and the n-th seat is the applicant suffixed with "#n",
+-- so that each seat is ranked by the time of its own application, and the seats key still counts all seats.
+local function seat_member(applicant, n)
+    if n <= 1 then
+        return applicant
+    end
+    return applicant .. "#" .. n
+end
+
+-- The number of seats of the applicant, which is tracked in the counts key.
+-- The applicant who is absent from the counts key has a seat if the applicant itself is in the seats key.
+local function count_seats(seats_key, counts_key, applicant)
+    local count = tonumber(redis.call("HGET", counts_key, applicant))
+    if count ~= nil then
+        return count
+    end
+    if redis.call("ZSCORE", seats_key, applicant) == false then
+        return 0
+    end
+    return 1
 end
 
 local function help_pop_applications_and_push_into_seats()
-    local content = {"Keys:", "`1`: applications key", "`2`: applicants_key", "`3`: seats key"}
+    local content = {"Keys:", "`1`: applications key", "`2`: applicants_key", "`3`: seats key",
+                     "`4`: dead-letter key, optional, the applications whose applicant is missing are dropped if absent",
+                     "`5`: waitlist key, optional, the applicants over capacity are dropped if absent or empty",
+                     "`6`: retry key, optional, the applications whose applicant is missing are not retried if absent or empty",
+                     "`7`: counts key, optional, each applicant has at most one seat if absent or empty",
+                     "`8`: registrations key, optional, the applicants are registered for the lottery instead of being seated if present",
+                     "Args:", "`1`: batch", "`2`: capacity, 0 or absent means unlimited",
+                     "`3`: retry delay in milliseconds", "`4`: retry attempts, 0 or absent means no retry",
+                     "`5`: quota, the maximum number of seats of each applicant, 1 or absent means one seat"}
     return redis.status_reply(table.concat(content, "\n"))
 end
 
@@ -36,37 +93,421 @@ local function pop_applications_and_push_into_seats(keys, args)
     local applications_key = keys[1]
     local applicants_key = keys[2]
     local seats_key = keys[3]
+    local dead_letters_key = keys[4]
+    local waitlist_key = keys[5]
+    local retry_key = keys[6]
+    local counts_key = keys[7]
+    local registrations_key = keys[8]
     local batch = args[1]
+    local capacity = tonumber(args[2]) or 0
+    local retry_delay = tonumber(args[3]) or 0
+    local retry_attempts = tonumber(args[4]) or 0
+    local quota = tonumber(args[5]) or 1
+    if dead_letters_key == "" then
+        dead_letters_key = nil
+    end
+    if waitlist_key == "" then
+        waitlist_key = nil
+    end
+    if counts_key == "" or quota <= 1 then
+        counts_key = nil
+    end
+    if retry_key == "" or retry_attempts == 0 then
+        retry_key = nil
+    end
+    if registrations_key == "" then
+        registrations_key = nil
+    end
 
-    local applications = redis.call("LPOP", applications_key, batch)
-    -- Return: total application, newly confirmed, application(s) skipped, applicant(s) missing.
-    if applications == false then
-        return {0, 0, 0, 0}
+    -- Return: total application, newly confirmed, application(s) skipped, applicant(s) missing,
+    -- application(s) over capacity, sold out, applicant(s) newly waitlisted, application(s) retried,
+    -- application(s) delayed for retry, applicant(s) newly registered.
+    local seats = 0
+    if capacity > 0 then
+        seats = redis.call("ZCARD", seats_key)
+        -- The applications keep being popped into the waitlist after selling out, if any.
+        if seats >= capacity and waitlist_key == nil then
+            return {0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+        end
     end
 
     -- Internal variables
     local newly_confirmed = 0
     local applicants_missing = 0
     local applications_skipped = 0
+    local applications_over_capacity = 0
+    local newly_waitlisted = 0
+    local applications_retried = 0
+    local applications_delayed = 0
+    local newly_registered = 0
 
-    for i=1,#applications do
-        if check_applicant_exists_by_application(applicants_key, applications[i]) == 1 then
-            local applicant = get_applicant_by_application(applicants_key, applications[i])
-            local count = push_applicant_into_seats(seats_key, applicant)
-            if count == 1 then
+    -- The score is when the application was popped, so that the seats are ranked by the time of application.
+    local function place(applicant, score)
+        -- The seats are drawn after the registration, see draw_lottery.
+        if registrations_key ~= nil then
+            if redis.call("SADD", registrations_key, applicant) == 1 then
+                newly_registered = newly_registered + 1
+            else
+                applications_skipped = applications_skipped + 1
+            end
+            return
+        end
+        -- The applicant is admitted until the quota is reached.
+        if counts_key ~= nil then
+            local count = count_seats(seats_key, counts_key, applicant)
+            if count >= quota then
+                applications_skipped = applications_skipped + 1
+            elseif capacity > 0 and seats >= capacity then
+                applications_over_capacity = applications_over_capacity + 1
+                if waitlist_key ~= nil and push_applicant_into_waitlist(waitlist_key, applicant, score) == 1 then
+                    newly_waitlisted = newly_waitlisted + 1
+                end
+            elseif push_applicant_into_seats(seats_key, seat_member(applicant, count + 1), score) == 1 then
+                redis.call("HSET", counts_key, applicant, count + 1)
                 newly_confirmed = newly_confirmed + 1
+                seats = seats + 1
             else
                 applications_skipped = applications_skipped + 1
             end
+            return
+        end
+        if capacity > 0 and seats >= capacity then
+            -- The applicant who already has a seat is still skipped after selling out.
+            if redis.call("ZSCORE", seats_key, applicant) == false then
+                applications_over_capacity = applications_over_capacity + 1
+                -- The applicant who has been waitlisted keeps the original position.
+                if waitlist_key ~= nil and push_applicant_into_waitlist(waitlist_key, applicant, score) == 1 then
+                    newly_waitlisted = newly_waitlisted + 1
+                end
+            else
+                applications_skipped = applications_skipped + 1
+            end
+        elseif push_applicant_into_seats(seats_key, applicant, score) == 1 then
+            newly_confirmed = newly_confirmed + 1
+            seats = seats + 1
+        else
+            applications_skipped = applications_skipped + 1
+        end
+    end
+
+    -- The application whose applicant is missing is retried later if it has not run out of attempts,
+    -- otherwise it is kept in the dead-letter list, in case the applicant is written later.
+    local function miss(application, popped_at, retries)
+        applicants_missing = applicants_missing + 1
+        if retry_key ~= nil and retries < retry_attempts then
+            push_application_into_retries(retry_key, application, popped_at, retries + 1, retry_delay)
+            applications_delayed = applications_delayed + 1
+        elseif dead_letters_key ~= nil then
+            push_application_into_dead_letters(dead_letters_key, application, popped_at)
+        end
+    end
+
+    -- The applications due for retry are processed before the new ones.
+    if retry_key ~= nil then
+        local due = redis.call("ZRANGEBYSCORE", retry_key, "-inf", get_timestamp_micro(), "LIMIT", 0, batch)
+        for i=1,#due do
+            redis.call("ZREM", retry_key, due[i])
+            local popped_at, retries, application = string.match(due[i], "^(%d+):(%d+):(.*)$")
+            applications_retried = applications_retried + 1
+            local applicant = get_applicant_by_application(applicants_key, application)
+            if applicant == false then
+                miss(application, popped_at, tonumber(retries))
+            else
+                place(applicant, popped_at)
+            end
+        end
+    end
+
+    local applications = redis.call("LPOP", applications_key, batch)
+    if applications == false then
+        applications = {}
+    end
+    for i=1,#applications do
+        local popped_at = get_timestamp_micro()
+        local applicant = get_applicant_by_application(applicants_key, applications[i])
+        if applicant == false then
+            miss(applications[i], popped_at, 0)
         else
+            place(applicant, popped_at)
+        end
+    end
+    local sold_out = 0
+    if capacity > 0 and seats >= capacity then
+        sold_out = 1
+    end
+    return {#applications, newly_confirmed, applications_skipped, applicants_missing, applications_over_capacity, sold_out,
+            newly_waitlisted, applications_retried, applications_delayed, newly_registered}
+end
+
+local function help_simulate_pop_applications_and_push_into_seats()
+    local content = {"Keys:", "`1`: applications key", "`2`: applicants_key", "`3`: seats key",
+                     "Args:", "`1`: offset", "`2`: batch", "`3`: capacity, 0 or absent means unlimited",
+                     "`4`: the number of seats simulated before, 0 or absent means none"}
+    return redis.status_reply(table.concat(content, "\n"))
+end
+
+-- The read-only variant of `pop_applications_and_push_into_seats`, which should be called with FCALL_RO.
+-- The applications are read from the offset instead of being popped, and nothing is written.
+-- The applicants that would be confirmed are returned as well, so that the caller can skip them in later batches.
+local function simulate_pop_applications_and_push_into_seats(keys, args)
+    local applications_key = keys[1]
+    local applicants_key = keys[2]
+    local seats_key = keys[3]
+    local offset = tonumber(args[1])
+    local batch = tonumber(args[2])
+    local capacity = tonumber(args[3]) or 0
+    local simulated = tonumber(args[4]) or 0
+
+    -- Return: total application, would be confirmed, application(s) skipped, applicant(s) missing,
+    -- application(s) over capacity, sold out, applicants that would be confirmed, seats that there would be.
+    local seats = redis.call("ZCARD", seats_key) + simulated
+    if capacity > 0 and seats >= capacity then
+        return {0, 0, 0, 0, 0, 1, {}, seats}
+    end
+
+    local applications = redis.call("LRANGE", applications_key, offset, offset + batch - 1)
+
+    local confirmed = {}
+    local would_be_confirmed = {}
+    local applicants_missing = 0
+    local applications_skipped = 0
+    local applications_over_capacity = 0
+
+    for i=1,#applications do
+        local applicant = get_applicant_by_application(applicants_key, applications[i])
+        if applicant == false then
             applicants_missing = applicants_missing + 1
+        elseif confirmed[applicant] or redis.call("ZSCORE", seats_key, applicant) ~= false then
+            applications_skipped = applications_skipped + 1
+        elseif capacity > 0 and seats >= capacity then
+            applications_over_capacity = applications_over_capacity + 1
+        else
+            confirmed[applicant] = true
+            table.insert(would_be_confirmed, applicant)
+            seats = seats + 1
+        end
+    end
+    local sold_out = 0
+    if capacity > 0 and seats >= capacity then
+        sold_out = 1
+    end
+    return {#applications, #would_be_confirmed, applications_skipped, applicants_missing, applications_over_capacity, sold_out,
+            would_be_confirmed, seats}
+end
+
+local function help_merge_seats()
+    local content = {"Keys:", "`1`: seats key", "`2`: waitlist key, optional, the applicants over capacity are dropped if absent",
+                     "Args:", "`1`: capacity, 0 means unlimited",
+                     "`2`, `3`, ...: pairs of score and applicant, in ascending order of score"}
+    return redis.status_reply(table.concat(content, "\n"))
+end
+
+-- Merge the seats confirmed elsewhere, such as in a partition located in another redis server, into the seats key.
+-- The scores are kept, so that the seats are still ranked by the time they were confirmed.
+local function merge_seats(keys, args)
+    local seats_key = keys[1]
+    local waitlist_key = keys[2]
+    local capacity = tonumber(args[1]) or 0
+
+    -- Return: newly confirmed, applicant(s) skipped, applicant(s) over capacity, sold out, applicant(s) newly waitlisted.
+    local seats = redis.call("ZCARD", seats_key)
+    local newly_confirmed = 0
+    local applicants_skipped = 0
+    local applicants_over_capacity = 0
+    local newly_waitlisted = 0
+
+    for i=2,#args,2 do
+        local score = args[i]
+        local applicant = args[i+1]
+        if redis.call("ZSCORE", seats_key, applicant) ~= false then
+            applicants_skipped = applicants_skipped + 1
+        elseif capacity > 0 and seats >= capacity then
+            applicants_over_capacity = applicants_over_capacity + 1
+            if waitlist_key ~= nil and redis.call("ZADD", waitlist_key, "NX", score, applicant) == 1 then
+                newly_waitlisted = newly_waitlisted + 1
+            end
+        else
+            redis.call("ZADD", seats_key, "NX", score, applicant)
+            newly_confirmed = newly_confirmed + 1
+            seats = seats + 1
+        end
+    end
+    local sold_out = 0
+    if capacity > 0 and seats >= capacity then
+        sold_out = 1
+    end
+    return {newly_confirmed, applicants_skipped, applicants_over_capacity, sold_out, newly_waitlisted}
+end
+
+local function help_revoke_seat()
+    local content = {"Keys:", "`1`: seats key", "`2`: revocations key",
+                     "`3`: waitlist key, optional, the seat is left vacant if absent or empty",
+                     "`4`: counts key, optional, each applicant has at most one seat if absent or empty",
+                     "Args:", "`1`: applicant", "`2`: reason", "`3`: capacity, 0 or absent means unlimited",
+                     "`4`: quota, the maximum number of seats of each applicant, 1 or absent means one seat"}
+    return redis.status_reply(table.concat(content, "\n"))
+end
+
+-- Revoke the latest seat of the applicant and record the reason,
+-- then promote the earliest applicant in the waitlist who has not reached the quota yet, if there is a vacancy.
+-- The promoted applicant keeps the score in the waitlist, which is when the application was popped,
+-- so that the seats are still ranked by the time of application.
+local function revoke_seat(keys, args)
+    local seats_key = keys[1]
+    local revocations_key = keys[2]
+    local waitlist_key = keys[3]
+    local counts_key = keys[4]
+    local applicant = args[1]
+    local reason = args[2]
+    local capacity = tonumber(args[3]) or 0
+    local quota = tonumber(args[4]) or 1
+    if waitlist_key == "" then
+        waitlist_key = nil
+    end
+    if counts_key == "" then
+        counts_key = nil
+    end
+
+    -- Return: revoked, and the applicant promoted if any.
+    local count = 1
+    if counts_key ~= nil then
+        count = count_seats(seats_key, counts_key, applicant)
+        if count == 0 then
+            return {0}
+        end
+    end
+    if redis.call("ZREM", seats_key, seat_member(applicant, count)) == 0 then
+        return {0}
+    end
+    if counts_key ~= nil then
+        if count <= 1 then
+            redis.call("HDEL", counts_key, applicant)
+        else
+            redis.call("HSET", counts_key, applicant, count - 1)
+        end
+    end
+    redis.call("HSET", revocations_key, applicant, reason)
+    if waitlist_key == nil then
+        return {1}
+    end
+    if capacity > 0 and redis.call("ZCARD", seats_key) >= capacity then
+        return {1}
+    end
+    while true do
+        local popped = redis.call("ZPOPMIN", waitlist_key)
+        if #popped == 0 then
+            return {1}
+        end
+        local candidate = popped[1]
+        if candidate ~= applicant then
+            local seated = 0
+            if counts_key ~= nil then
+                seated = count_seats(seats_key, counts_key, candidate)
+            elseif redis.call("ZSCORE", seats_key, candidate) ~= false then
+                seated = 1
+            end
+            if seated < quota and redis.call("ZADD", seats_key, "NX", popped[2], seat_member(candidate, seated + 1)) == 1 then
+                if counts_key ~= nil then
+                    redis.call("HSET", counts_key, candidate, seated + 1)
+                end
+                return {1, candidate}
+            end
+        end
+    end
+end
+
+local function help_requeue_dead_letters()
+    local content = {"Keys:", "`1`: dead-letter key", "`2`: applications key",
+                     "Args:", "`1`: count, 0 or absent means all"}
+    return redis.status_reply(table.concat(content, "\n"))
+end
+
+-- Move the earliest applications in the dead-letter list back to the front of the applications,
+-- in the order they were popped, so that they are popped first in the next batch.
+local function requeue_dead_letters(keys, args)
+    local dead_letters_key = keys[1]
+    local applications_key = keys[2]
+    local count = tonumber(args[1]) or 0
+
+    -- Return: application(s) requeued.
+    local entries = redis.call("LRANGE", dead_letters_key, 0, count - 1)
+    if #entries == 0 then
+        return 0
+    end
+    -- Parse all entries before trimming, so that nothing is lost.
+    local applications = {}
+    for i=1,#entries do
+        applications[i] = parse_dead_letter(entries[i])
+    end
+    redis.call("LTRIM", dead_letters_key, #entries, -1)
+    for i=#applications,1,-1 do
+        redis.call("LPUSH", applications_key, applications[i])
+    end
+    return #applications
+end
+
+local function help_draw_lottery()
+    local content = {"Keys:", "`1`: registrations key", "`2`: seats key",
+                     "`3`: waitlist key, optional, the applicants not drawn are dropped if absent or empty", "`4`: draws key",
+                     "Args:", "`1`: seed", "`2`: capacity, 0 or absent means unlimited"}
+    return redis.status_reply(table.concat(content, "\n"))
+end
+
+-- Draw the winners among the registered applicants with the seed, only once.
+-- The ticket of each applicant is the SHA1 of the seed and the applicant joined by a colon,
+-- and the applicants are ranked by their tickets, so that anyone can reproduce the draw with the seed and the registrations.
+-- The first applicants fill the vacant seats, and the rest are put into the waitlist in the same order.
+-- The score of the n-th applicant is the time of the draw plus n microseconds, so that the seats are ranked by the draw.
+-- The seed, the time, the numbers and the digest of the ranking are recorded in the draws key for the audit.
+local function draw_lottery(keys, args)
+    local registrations_key = keys[1]
+    local seats_key = keys[2]
+    local waitlist_key = keys[3]
+    local draws_key = keys[4]
+    local seed = args[1]
+    local capacity = tonumber(args[2]) or 0
+    if waitlist_key == "" then
+        waitlist_key = nil
+    end
+
+    -- Return: drawn, time of the draw, applicant(s) registered, applicant(s) seated, applicant(s) waitlisted, digest.
+    if redis.call("EXISTS", draws_key) == 1 then
+        return {0}
+    end
+    local applicants = redis.call("SMEMBERS", registrations_key)
+    local tickets = {}
+    for i=1,#applicants do
+        tickets[applicants[i]] = redis.sha1hex(seed .. ":" .. applicants[i])
+    end
+    table.sort(applicants, function(a, b)
+        if tickets[a] == tickets[b] then
+            return a < b
+        end
+        return tickets[a] < tickets[b]
+    end)
+
+    local vacancies = #applicants
+    if capacity > 0 then
+        vacancies = math.max(capacity - redis.call("ZCARD", seats_key), 0)
+    end
+    local now = get_timestamp_micro()
+    local seated = 0
+    local waitlisted = 0
+    for i=1,#applicants do
+        if i <= vacancies then
+            seated = seated + push_applicant_into_seats(seats_key, applicants[i], now + i)
+        elseif waitlist_key ~= nil then
+            waitlisted = waitlisted + push_applicant_into_waitlist(waitlist_key, applicants[i], now + i)
         end
     end
-    return {#applications, newly_confirmed, applications_skipped, applicants_missing}
+    local digest = redis.sha1hex(table.concat(applicants, "\n"))
+    redis.call("HSET", draws_key, "seed", seed, "time", now, "registered", #applicants, "seated", seated,
+            "waitlisted", waitlisted, "digest", digest)
+    return {1, now, #applicants, seated, waitlisted, digest}
 end
 
 local function go_rush_consumer_version(keys, args)
-    return {0, 0, 1}
+    return {0, 9, 0}
 end
 
 local function go_rush_consumer_help(keys, args)
@@ -75,15 +516,39 @@ local function go_rush_consumer_help(keys, args)
                 table.concat({
                     "Functions: ",
                     "`go_rush_consumer_version`: The version of `go_rush_consumer` module.",
-                    "`pop_applications_and_push_into_seats`: Pop the farthest applications and confirm them with seats."
+                    "`pop_applications_and_push_into_seats`: Pop the farthest applications and confirm them with seats.",
+                    "`merge_seats`: Merge the seats confirmed elsewhere into the seats, keeping their scores.",
+                    "`simulate_pop_applications_and_push_into_seats`: The read-only variant of `pop_applications_and_push_into_seats`.",
+                    "`revoke_seat`: Revoke a seat and promote the earliest applicant in the waitlist.",
+                    "`requeue_dead_letters`: Move the applications in the dead-letter list back to the front of the applications.",
+                    "`draw_lottery`: Draw the winners among the registered applicants into the seats with an auditable seed."
         }, "\n"))
     end
     local key = keys[1]
     if key == 'pop_applications_and_push_into_seats' then
         return help_pop_applications_and_push_into_seats()
+    elseif key == 'merge_seats' then
+        return help_merge_seats()
+    elseif key == 'simulate_pop_applications_and_push_into_seats' then
+        return help_simulate_pop_applications_and_push_into_seats()
+    elseif key == 'revoke_seat' then
+        return help_revoke_seat()
+    elseif key == 'requeue_dead_letters' then
+        return help_requeue_dead_letters()
+    elseif key == 'draw_lottery' then
+        return help_draw_lottery()
     end
 end
 
 redis.register_function('pop_applications_and_push_into_seats', pop_applications_and_push_into_seats)
+redis.register_function('merge_seats', merge_seats)
+redis.register_function('revoke_seat', revoke_seat)
+redis.register_function('requeue_dead_letters', requeue_dead_letters)
+redis.register_function('draw_lottery', draw_lottery)
+redis.register_function{
+    function_name='simulate_pop_applications_and_push_into_seats',
+    callback=simulate_pop_applications_and_push_into_seats,
+    flags={'no-writes'}
+}
 redis.register_function('go_rush_consumer_version', go_rush_consumer_version)
 redis.register_function('go_rush_consumer_help', go_rush_consumer_help)
\ No newline at end of file
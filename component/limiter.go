@@ -0,0 +1,294 @@
+package component
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrActivityWeightInvalid = errors.New("the weight must be greater than 0")
+
+// RedisServerLimit caps the batches of all activities processed on a redis server. 0 means unlimited.
+type RedisServerLimit struct {
+	MaxConcurrent uint16 `yaml:"MaxConcurrent,omitempty" json:"max_concurrent"` // The maximum number of batches in flight.
+	MaxPerSecond  uint16 `yaml:"MaxPerSecond,omitempty" json:"max_per_second"`  // The maximum number of batches started per second.
+}
+
+// limiterWaiter represents a batch waiting for the limiter.
+type limiterWaiter struct {
+	start  float64 // The virtual start time, the waiter with the smallest one is granted first.
+	finish float64 // The virtual finish time, which is the start of the next batch of the same activity.
+	seq    uint64  // The order of arrival, to break ties.
+	id     uint64
+	ready  chan struct{}
+	index  int // The position in the queue, -1 after being granted.
+}
+
+type limiterQueue []*limiterWaiter
+
+func (q limiterQueue) Len() int { return len(q) }
+
+func (q limiterQueue) Less(i, j int) bool {
+	if q[i].start == q[j].start {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].start < q[j].start
+}
+
+func (q limiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *limiterQueue) Push(x any) {
+	w := x.(*limiterWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *limiterQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// redisServerLimiter schedules the batches of all activities on a redis server.
+//
+// The batches waiting are granted by start-time fair queuing: each batch of an activity advances the virtual time of
+// that activity by 1/weight, and the batch with the smallest virtual start time is granted first.
+// Therefore, the activities competing for the redis server share it in proportion to their weights,
+// and an activity that has been idle does not accumulate credit. The finish time of an activity is kept even if
+// no batch is waiting, so an activity that has just been granted cannot skip ahead of the others after an idle gap.
+// The per-second limit is a token bucket whose capacity is one second of batches.
+type redisServerLimiter struct {
+	lock     sync.Mutex
+	limit    RedisServerLimit
+	inFlight int
+	tokens   float64
+	refilled time.Time
+	virtual  float64            // The start time of the batch granted last.
+	finish   map[uint64]float64 // The finish time of the last batch of each activity, kept until the virtual time passes it.
+	queue    limiterQueue
+	seq      uint64
+	timer    *time.Timer // Wakes up the queue when the next token is available.
+	granted  uint64
+}
+
+func newRedisServerLimiter(limit RedisServerLimit) *redisServerLimiter {
+	return &redisServerLimiter{
+		limit:    limit,
+		tokens:   float64(limit.MaxPerSecond),
+		refilled: time.Now(),
+		finish:   make(map[uint64]float64),
+	}
+}
+
+// acquire waits until the batch of the activity is granted, or the context is done.
+// The returned function must be called once the batch is finished.
+// If the context is done before being granted, the cause of the context will be returned.
+func (l *redisServerLimiter) acquire(ctx context.Context, id uint64, weight uint16) (func(), error) {
+	if weight == 0 {
+		weight = 1
+	}
+	l.lock.Lock()
+	start := math.Max(l.virtual, l.finish[id])
+	w := &limiterWaiter{start: start, finish: start + 1/float64(weight), seq: l.seq, id: id, ready: make(chan struct{})}
+	l.seq++
+	l.finish[id] = w.finish
+	heap.Push(&l.queue, w)
+	l.dispatch()
+	l.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if w.index < 0 {
+			// Granted while being cancelled.
+			l.release()
+			return nil, context.Cause(ctx)
+		}
+		heap.Remove(&l.queue, w.index)
+		if l.finish[id] == w.finish {
+			l.finish[id] = w.start
+		}
+		l.dispatch()
+		return nil, context.Cause(ctx)
+	}
+}
+
+func (l *redisServerLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			l.release()
+		})
+	}
+}
+
+// release frees the slot of a finished batch. The caller must hold the lock.
+func (l *redisServerLimiter) release() {
+	l.inFlight--
+	l.dispatch()
+}
+
+// refill adds the tokens generated since the last refill. The caller must hold the lock.
+func (l *redisServerLimiter) refill(now time.Time) {
+	rate := float64(l.limit.MaxPerSecond)
+	l.tokens = math.Min(rate, l.tokens+now.Sub(l.refilled).Seconds()*rate)
+	l.refilled = now
+}
+
+// dispatch grants the waiters as long as the limit allows. The caller must hold the lock.
+func (l *redisServerLimiter) dispatch() {
+	for len(l.queue) > 0 {
+		if l.limit.MaxConcurrent > 0 && l.inFlight >= int(l.limit.MaxConcurrent) {
+			return
+		}
+		if l.limit.MaxPerSecond > 0 {
+			l.refill(time.Now())
+			if l.tokens < 1 {
+				if l.timer == nil {
+					delay := time.Duration((1 - l.tokens) / float64(l.limit.MaxPerSecond) * float64(time.Second))
+					l.timer = time.AfterFunc(delay, func() {
+						l.lock.Lock()
+						defer l.lock.Unlock()
+						l.timer = nil
+						l.dispatch()
+					})
+				}
+				return
+			}
+			l.tokens--
+		}
+		w := heap.Pop(&l.queue).(*limiterWaiter)
+		l.virtual = w.start
+		l.inFlight++
+		l.granted++
+		close(w.ready)
+	}
+	// The finish times that the virtual time has passed no longer affect the start of the next batch.
+	for id, finish := range l.finish {
+		if finish <= l.virtual {
+			delete(l.finish, id)
+		}
+	}
+}
+
+// setLimit changes the limit, which takes effect on the waiters immediately.
+func (l *redisServerLimiter) setLimit(limit RedisServerLimit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.refill(time.Now())
+	l.limit = limit
+	l.tokens = math.Min(l.tokens, float64(limit.MaxPerSecond))
+	l.dispatch()
+}
+
+// RedisServerLimiterStatus reports the limiter of a redis server.
+type RedisServerLimiterStatus struct {
+	RedisServerIndex uint8            `json:"redis_server_index"`
+	Limit            RedisServerLimit `json:"limit"`
+	InFlight         int              `json:"in_flight"` // The number of batches being processed.
+	Waiting          int              `json:"waiting"`   // The number of batches waiting to be granted.
+	Granted          uint64           `json:"granted"`   // The total number of batches granted.
+}
+
+func (l *redisServerLimiter) status(index uint8) RedisServerLimiterStatus {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return RedisServerLimiterStatus{
+		RedisServerIndex: index,
+		Limit:            l.limit,
+		InFlight:         l.inFlight,
+		Waiting:          len(l.queue),
+		Granted:          l.granted,
+	}
+}
+
+var redisServerLimiters = make(map[uint8]*redisServerLimiter)
+var redisServerLimitersRWLock sync.RWMutex
+
+// defaultRedisServerLimit returns the limit configured for the redis server, see EnvActivityRedisServer.Limits.
+func defaultRedisServerLimit(index uint8) RedisServerLimit {
+	if GlobalEnv == nil || GlobalEnv.Activity == nil || GlobalEnv.Activity.RedisServer == nil {
+		return RedisServerLimit{}
+	}
+	return GlobalEnv.Activity.RedisServer.GetLimit(index)
+}
+
+// getRedisServerLimiter returns the limiter of the redis server, which is created with the configured limit on first use.
+func getRedisServerLimiter(index uint8) *redisServerLimiter {
+	redisServerLimitersRWLock.RLock()
+	limiter, existed := redisServerLimiters[index]
+	redisServerLimitersRWLock.RUnlock()
+	if existed {
+		return limiter
+	}
+	redisServerLimitersRWLock.Lock()
+	defer redisServerLimitersRWLock.Unlock()
+	if limiter, existed = redisServerLimiters[index]; !existed {
+		limiter = newRedisServerLimiter(defaultRedisServerLimit(index))
+		redisServerLimiters[index] = limiter
+	}
+	return limiter
+}
+
+// SetRedisServerLimit changes the limit of the redis server at runtime, overriding the configured one.
+func SetRedisServerLimit(index uint8, limit RedisServerLimit) {
+	getRedisServerLimiter(index).setLimit(limit)
+}
+
+// RedisServerLimiters returns the status of the limiters that have been used, ordered by the index of the redis server.
+func RedisServerLimiters() []RedisServerLimiterStatus {
+	redisServerLimitersRWLock.RLock()
+	defer redisServerLimitersRWLock.RUnlock()
+	items := make([]RedisServerLimiterStatus, 0, len(redisServerLimiters))
+	for index, limiter := range redisServerLimiters {
+		items = append(items, limiter.status(index))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].RedisServerIndex < items[j].RedisServerIndex
+	})
+	return items
+}
+
+// WithWeight specifies the share of the activity when competing with others for a limited redis server.
+// If not specified, EnvActivity.Weight is used.
+func WithWeight(weight uint16) ActivityOption {
+	return func(activity *Activity) {
+		activity.Weight = weight
+	}
+}
+
+// defaultActivityWeight returns the weight configured, or the default value if the environment has not been loaded.
+func defaultActivityWeight() uint16 {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Weight != nil {
+		return *GlobalEnv.Activity.Weight
+	}
+	return *(&EnvActivity{}).GetWeightDefault()
+}
+
+// GetWeight returns the share of the activity when competing with others for a limited redis server.
+func (c *Activity) GetWeight() uint16 {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.Weight
+}
+
+// acquire waits for the limiter of the redis server to grant a batch of the activity, see redisServerLimiter.
+func (c *Activity) acquire(ctx context.Context, index uint8) (func(), error) {
+	return getRedisServerLimiter(index).acquire(ctx, c.ID, c.GetWeight())
+}
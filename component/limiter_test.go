@@ -0,0 +1,133 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type limiterGrant struct {
+	id      uint64
+	release func()
+}
+
+// enqueue acquires the limiter in a coroutine, and waits until it is queued, so that the order of arrival is determined.
+func enqueue(t *testing.T, l *redisServerLimiter, ctx context.Context, id uint64, weight uint16, grants chan<- limiterGrant) {
+	l.lock.Lock()
+	waiting := len(l.queue)
+	l.lock.Unlock()
+	go func() {
+		release, err := l.acquire(ctx, id, weight)
+		if err == nil {
+			grants <- limiterGrant{id: id, release: release}
+		}
+	}()
+	assert.Eventually(t, func() bool {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return len(l.queue) > waiting
+	}, time.Second, time.Millisecond)
+}
+
+func TestRedisServerLimiter_Weight(t *testing.T) {
+	l := newRedisServerLimiter(RedisServerLimit{MaxConcurrent: 1})
+	hold, err := l.acquire(context.Background(), 0, 1)
+	assert.Nil(t, err)
+
+	// Each activity has two partitions, and each partition has only one batch outstanding at a time:
+	// it acquires again only after its batch is released.
+	weights := map[uint64]uint16{1: 3, 2: 1}
+	grants := make(chan limiterGrant)
+	for _, id := range []uint64{1, 2, 1, 2} {
+		enqueue(t, l, context.Background(), id, weights[id], grants)
+	}
+	hold()
+	counts := make(map[uint64]int)
+	grant := <-grants
+	for i := 0; i < 80; i++ {
+		assert.Equal(t, 1, l.status(0).InFlight, "Only one batch should be in flight.")
+		counts[grant.id]++
+		grant.release()
+		next := <-grants
+		enqueue(t, l, context.Background(), grant.id, weights[grant.id], grants)
+		grant = next
+	}
+	assert.InDelta(t, 3, float64(counts[1])/float64(counts[2]), 0.5, "The activity with weight 3 should be granted 3 times as often while competing: %v", counts)
+}
+
+func TestRedisServerLimiter_IdleGap(t *testing.T) {
+	l := newRedisServerLimiter(RedisServerLimit{MaxConcurrent: 1})
+	release, err := l.acquire(context.Background(), 2, 1)
+	assert.Nil(t, err)
+	release()
+	// The queue is empty now, but the batch of activity 2 has just been granted.
+	hold, err := l.acquire(context.Background(), 3, 1)
+	assert.Nil(t, err)
+
+	grants := make(chan limiterGrant)
+	enqueue(t, l, context.Background(), 2, 1, grants)
+	enqueue(t, l, context.Background(), 1, 1, grants)
+	hold()
+	grant := <-grants
+	assert.Equal(t, uint64(1), grant.id, "The finish time of activity 2 should be kept across the idle gap.")
+	grant.release()
+	grant = <-grants
+	assert.Equal(t, uint64(2), grant.id)
+	grant.release()
+	assert.Equal(t, 0, l.status(0).InFlight)
+}
+
+func TestRedisServerLimiter_Cancel(t *testing.T) {
+	l := newRedisServerLimiter(RedisServerLimit{MaxConcurrent: 1})
+	hold, err := l.acquire(context.Background(), 0, 1)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := l.acquire(ctx, 1, 1)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return l.status(0).Waiting == 1 }, time.Second, time.Millisecond)
+	cancel(ErrWorkerStopped)
+	assert.ErrorIs(t, <-done, ErrWorkerStopped)
+	assert.Equal(t, 0, l.status(0).Waiting, "The waiter cancelled should be removed.")
+	hold()
+	hold()
+	assert.Equal(t, 0, l.status(0).InFlight, "Releasing twice should take no effect.")
+}
+
+func TestRedisServerLimiter_PerSecond(t *testing.T) {
+	l := newRedisServerLimiter(RedisServerLimit{MaxPerSecond: 20})
+	tmStart := time.Now()
+	for i := 0; i < 25; i++ {
+		release, err := l.acquire(context.Background(), 1, 1)
+		assert.Nil(t, err)
+		release()
+	}
+	assert.GreaterOrEqual(t, time.Since(tmStart), 200*time.Millisecond, "The batches beyond the burst should be paced.")
+
+	SetRedisServerLimit(255, RedisServerLimit{MaxConcurrent: 2})
+	defer func() {
+		redisServerLimitersRWLock.Lock()
+		delete(redisServerLimiters, 255)
+		redisServerLimitersRWLock.Unlock()
+	}()
+	limiters := RedisServerLimiters()
+	assert.Equal(t, RedisServerLimit{MaxConcurrent: 2}, limiters[len(limiters)-1].Limit)
+}
+
+func TestActivity_Weight(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, defaultActivityWeight(), activity.GetWeight())
+	zero, weight := uint16(0), uint16(5)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Weight: &zero}), ErrActivityWeightInvalid)
+	assert.Nil(t, activity.Update(&ActivitySettings{Weight: &weight}))
+	assert.Equal(t, weight, activity.Status().Weight)
+}
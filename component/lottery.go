@@ -0,0 +1,202 @@
+package component
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrActivityAllocationInvalid = errors.New("the allocation must be fifo or lottery")
+var ErrActivityLotteryInvalid = errors.New("the lottery requires the function processor, a quota of 1, and all partitions located in the redis server of the seats")
+var ErrActivityNotLottery = errors.New("the seats of the activity are not allocated by lottery")
+var ErrActivityAlreadyDrawn = errors.New("the lottery has already been drawn")
+var ErrActivityNotDrawn = errors.New("the lottery has not been drawn")
+
+// ActivityAllocation represents how the seats are allocated to the applicants.
+type ActivityAllocation string
+
+const (
+	// ActivityAllocationFIFO means that the seats are confirmed in the order of the applications.
+	ActivityAllocationFIFO ActivityAllocation = "fifo"
+	// ActivityAllocationLottery means that the applicants are registered while the worker is working,
+	// and the seats are drawn among them afterward, see Activity.Draw().
+	ActivityAllocationLottery ActivityAllocation = "lottery"
+)
+
+// Validate checks whether the allocation is known.
+func (a ActivityAllocation) Validate() error {
+	if a != ActivityAllocationFIFO && a != ActivityAllocationLottery {
+		return ErrActivityAllocationInvalid
+	}
+	return nil
+}
+
+// ActivityDraw represents the record of the lottery, which is enough to reproduce the draw.
+type ActivityDraw struct {
+	Seed       string    `json:"seed"`
+	Time       time.Time `json:"time"`
+	Registered uint64    `json:"registered"` // The number of applicants registered.
+	Seated     uint64    `json:"seated"`     // The number of applicants drawn into the seats.
+	Waitlisted uint64    `json:"waitlisted"` // The number of applicants put into the waitlist.
+	Digest     string    `json:"digest"`     // The SHA1 of the applicants in the order drawn, joined by newlines.
+}
+
+// WithAllocation specifies how the seats are allocated. If not specified, the seats are confirmed first-come-first-served.
+//
+// In the lottery, each application popped registers its applicant in the registration key of the activity,
+// and the applicant registered more than once is skipped. The capacity is not checked while registering,
+// and the worker keeps working until it is stopped, such as at EndAt.
+// After the registration window, Activity.Draw() draws the winners with a seed.
+// The lottery is only supported by the function processor, see ProcessorFunction,
+// and it requires a quota of 1 and no partition located in another redis server.
+// The registrations are not simulated, so the lottery cannot be enabled in dry-run mode, see WithDryRun().
+func WithAllocation(allocation ActivityAllocation) ActivityOption {
+	return func(activity *Activity) {
+		activity.Allocation = allocation
+	}
+}
+
+// IsLottery determines whether the seats are allocated by lottery.
+func (c *Activity) IsLottery() bool {
+	return c.Allocation == ActivityAllocationLottery
+}
+
+// validateAllocation checks the allocation against the processor and the partitions.
+// The quota is checked by validateQuota().
+func (c *Activity) validateAllocation() error {
+	if err := c.Allocation.Validate(); err != nil {
+		return err
+	}
+	if !c.IsLottery() {
+		return nil
+	}
+	if c.Processor != ProcessorFunction {
+		return ErrActivityLotteryInvalid
+	}
+	for i := range c.Partitions {
+		if c.isRemotePartition(i) {
+			return ErrActivityLotteryInvalid
+		}
+	}
+	return nil
+}
+
+func (c *Activity) GetRedisServerRegistrationKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Registration, c.ID)
+}
+
+func (c *Activity) GetRedisServerDrawKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Draw, c.ID)
+}
+
+// newLotterySeed returns a random seed of 128 bits in hex.
+func newLotterySeed() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Draw draws the winners among the registered applicants with the seed by the redis function "draw_lottery",
+// and generates a random seed if the seed is empty.
+//
+// The ticket of each applicant is the hex SHA1 of the seed and the applicant joined by a colon, such as "seed:applicant",
+// and the applicants are ranked by their tickets. The first applicants fill the vacant seats, which are all seats if the capacity is 0,
+// and the rest are put into the waitlist in the same order, whether the waitlist is enabled or not.
+// Since the registrations are kept, anyone can reproduce the draw with the seed recorded, see GetDraw().
+//
+// The lottery can be drawn only once. If it has been drawn, an ErrActivityAlreadyDrawn error will be returned.
+// If the seats are not allocated by lottery, an ErrActivityNotLottery error will be returned,
+// and if the worker is working, an ErrWorkerIsWorking error will be returned, since the registration is still open.
+// If the worker has been stopped but not exited yet, an ErrWorkerIsDraining error will be returned,
+// since the batch in progress may still register applicants.
+func (c *Activity) Draw(ctx context.Context, seed string) (*ActivityDraw, error) {
+	if !c.IsLottery() {
+		return nil, ErrActivityNotLottery
+	}
+	if c.IsWorking() {
+		return nil, ErrWorkerIsWorking
+	}
+	if exited := c.exitedChan(); exited != nil {
+		select {
+		case <-exited:
+		default:
+			return nil, ErrWorkerIsDraining
+		}
+	}
+	if len(seed) == 0 {
+		var err error
+		if seed, err = newLotterySeed(); err != nil {
+			return nil, err
+		}
+	}
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	val, err := client.FCall(ctx, "draw_lottery", []string{
+		c.GetRedisServerRegistrationKeyName(),
+		c.GetRedisServerSeatKeyName(),
+		c.GetRedisServerWaitlistKeyName(),
+		c.GetRedisServerDrawKeyName(),
+	}, seed, c.Capacity).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseDraw(seed, val)
+}
+
+// parseDraw parses the reply of the redis function.
+func parseDraw(seed string, val []interface{}) (*ActivityDraw, error) {
+	if drawn, _ := val[0].(int64); drawn == 0 {
+		return nil, ErrActivityAlreadyDrawn
+	}
+	tm, _ := val[1].(int64)
+	registered, _ := val[2].(int64)
+	seated, _ := val[3].(int64)
+	waitlisted, _ := val[4].(int64)
+	digest, _ := val[5].(string)
+	return &ActivityDraw{
+		Seed:       seed,
+		Time:       time.UnixMicro(tm),
+		Registered: uint64(registered),
+		Seated:     uint64(seated),
+		Waitlisted: uint64(waitlisted),
+		Digest:     digest,
+	}, nil
+}
+
+// GetDraw returns the record of the lottery.
+// If the lottery has not been drawn, an ErrActivityNotDrawn error will be returned.
+func (c *Activity) GetDraw(ctx context.Context) (*ActivityDraw, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	fields, err := client.HGetAll(ctx, c.GetRedisServerDrawKeyName()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrActivityNotDrawn
+	}
+	tm, _ := strconv.ParseInt(fields["time"], 10, 64)
+	registered, _ := strconv.ParseUint(fields["registered"], 10, 64)
+	seated, _ := strconv.ParseUint(fields["seated"], 10, 64)
+	waitlisted, _ := strconv.ParseUint(fields["waitlisted"], 10, 64)
+	return &ActivityDraw{
+		Seed:       fields["seed"],
+		Time:       time.UnixMicro(tm),
+		Registered: registered,
+		Seated:     seated,
+		Waitlisted: waitlisted,
+		Digest:     fields["digest"],
+	}, nil
+}
+
+// CountRegistrations returns the number of applicants registered for the lottery.
+func (c *Activity) CountRegistrations(ctx context.Context) (int64, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	return client.SCard(ctx, c.GetRedisServerRegistrationKeyName()).Result()
+}
@@ -0,0 +1,70 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_Lottery(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithAllocation("raffle")), ErrActivityAllocationInvalid)
+	assert.ErrorIs(t, Activities.New(1, nil, WithAllocation(ActivityAllocationLottery), WithProcessor(ProcessorNaive)), ErrActivityLotteryInvalid)
+	assert.ErrorIs(t, Activities.New(1, nil, WithAllocation(ActivityAllocationLottery), WithQuota(2)), ErrActivityLotteryInvalid)
+	assert.ErrorIs(t, Activities.New(1, nil, WithAllocation(ActivityAllocationLottery), WithPartitions(NewActivityPartitions([]uint8{0, 1}))), ErrActivityLotteryInvalid)
+
+	assert.Nil(t, Activities.New(1, nil, WithCapacity(10)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, ActivityAllocationFIFO, activity.Status().Allocation)
+	assert.Empty(t, activity.newBatchTask(0).RegistrationKey)
+	_, err := activity.Draw(context.Background(), "seed")
+	assert.ErrorIs(t, err, ErrActivityNotLottery)
+
+	assert.Nil(t, Activities.New(2, nil, WithCapacity(10), WithWaitlist(true), WithAllocation(ActivityAllocationLottery)))
+	activity, _ = Activities.GetActivity(2)
+	assert.True(t, activity.IsLottery())
+	assert.Equal(t, ActivityAllocationLottery, activity.record().Allocation)
+	task := activity.newBatchTask(0)
+	assert.Equal(t, "activity_registration_2", task.RegistrationKey)
+	assert.Equal(t, uint64(0), task.Capacity, "The capacity should not be checked while registering.")
+	assert.Empty(t, task.WaitlistKey)
+	quota := uint16(2)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Quota: &quota}), ErrActivityLotteryInvalid)
+
+	activity.recordStats(time.Now(), &BatchResult{Total: 3, Skipped: 1, Registered: 2})
+	assert.Equal(t, uint64(2), activity.Counters().Registered)
+
+	assert.ErrorIs(t, (&ActivityTemplate{Name: "raffle", Allocation: ActivityAllocationLottery, Processor: ProcessorTransaction}).Validate(), ErrActivityLotteryInvalid)
+	assert.Nil(t, (&ActivityTemplate{Name: "raffle", Allocation: ActivityAllocationLottery}).Validate())
+
+	// The batch in progress may still register applicants until the worker exits.
+	exited := make(chan struct{})
+	activity.contextCancelFuncRWLock.Lock()
+	activity.exited = exited
+	activity.contextCancelFuncRWLock.Unlock()
+	_, err = activity.Draw(context.Background(), "seed")
+	assert.ErrorIs(t, err, ErrWorkerIsDraining)
+	close(exited)
+
+	assert.ErrorIs(t, Activities.New(3, nil, WithAllocation(ActivityAllocationLottery), WithDryRun(true)), ErrActivityDryRunUnsupported)
+}
+
+func TestActivity_ParseDraw(t *testing.T) {
+	_, err := parseDraw("seed", []interface{}{int64(0)})
+	assert.ErrorIs(t, err, ErrActivityAlreadyDrawn)
+
+	draw, err := parseDraw("seed", []interface{}{int64(1), int64(1700000000123456), int64(5), int64(2), int64(3), "digest"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ActivityDraw{
+		Seed:       "seed",
+		Time:       time.UnixMicro(1700000000123456),
+		Registered: 5,
+		Seated:     2,
+		Waitlisted: 3,
+		Digest:     "digest",
+	}, draw)
+}
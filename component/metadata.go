@@ -0,0 +1,107 @@
+package component
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrActivityLabelInvalid = errors.New("the label must be in the form of key=value")
+
+// ActivityMetadata describes the activity for people. It does not affect the worker.
+type ActivityMetadata struct {
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	CreatedBy   string            `json:"created_by,omitempty"`
+}
+
+// WithMetadata specifies the metadata of the activity. If metadata.CreatedAt is zero, it will be the time of creation.
+func WithMetadata(metadata ActivityMetadata) ActivityOption {
+	return func(activity *Activity) {
+		activity.Metadata = metadata
+	}
+}
+
+// WithCreatedBy specifies who creates the activity, without changing other metadata.
+func WithCreatedBy(createdBy string) ActivityOption {
+	return func(activity *Activity) {
+		activity.Metadata.CreatedBy = createdBy
+	}
+}
+
+// ParseActivityLabels parses labels in the form of key=value.
+// If any of them is not in that form, an ErrActivityLabelInvalid error will be returned.
+func ParseActivityLabels(labels []string) (map[string]string, error) {
+	result := make(map[string]string, len(labels))
+	for _, v := range labels {
+		key, value, found := strings.Cut(v, "=")
+		if !found || len(key) == 0 {
+			return nil, ErrActivityLabelInvalid
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// ActivityFilter specifies the conditions of listing activities. The zero value of each condition means unlimited.
+type ActivityFilter struct {
+	Labels           map[string]string // The activity must have all these labels with the same values.
+	State            ActivityState
+	RedisServerIndex *uint8
+	Offset           int
+	Limit            int
+}
+
+// match determines whether the activity satisfies the filter, except offset and limit.
+func (f *ActivityFilter) match(activity *Activity) bool {
+	if f.RedisServerIndex != nil && *f.RedisServerIndex != activity.RedisServerIndex {
+		return false
+	}
+	if len(f.State) > 0 && f.State != activity.State() {
+		return false
+	}
+	for key, value := range f.Labels {
+		if v, existed := activity.Metadata.Labels[key]; !existed || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the status of the activities that satisfy the filter, ordered by activity ID,
+// and the total number of them regardless of offset and limit.
+func (a *ActivityPool) List(filter *ActivityFilter) ([]ActivityStatus, int) {
+	if filter == nil {
+		filter = &ActivityFilter{}
+	}
+	a.ActivitiesRWLock.RLock()
+	activities := make([]*Activity, 0, len(a.Activities))
+	for _, v := range a.Activities {
+		if filter.match(v) {
+			activities = append(activities, v)
+		}
+	}
+	a.ActivitiesRWLock.RUnlock()
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].ID < activities[j].ID
+	})
+	total := len(activities)
+	if filter.Offset > 0 {
+		if filter.Offset >= total {
+			activities = activities[:0]
+		} else {
+			activities = activities[filter.Offset:]
+		}
+	}
+	if filter.Limit > 0 && filter.Limit < len(activities) {
+		activities = activities[:filter.Limit]
+	}
+	items := make([]ActivityStatus, len(activities))
+	for i, v := range activities {
+		items[i] = v.Status()
+	}
+	return items, total
+}
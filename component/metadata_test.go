@@ -0,0 +1,55 @@
+package component
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseActivityLabels(t *testing.T) {
+	labels, err := ParseActivityLabels([]string{"brand=acme", "kind=weekly", "empty="})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"brand": "acme", "kind": "weekly", "empty": ""}, labels)
+
+	_, err = ParseActivityLabels([]string{"brand"})
+	assert.ErrorIs(t, err, ErrActivityLabelInvalid)
+	_, err = ParseActivityLabels([]string{"=acme"})
+	assert.ErrorIs(t, err, ErrActivityLabelInvalid)
+}
+
+func TestActivityPool_List(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	index := uint8(1)
+	assert.Nil(t, Activities.New(3, nil, WithMetadata(ActivityMetadata{Name: "c", Labels: map[string]string{"kind": "weekly"}})))
+	assert.Nil(t, Activities.New(1, nil, WithMetadata(ActivityMetadata{Name: "a", Labels: map[string]string{"kind": "weekly", "brand": "acme"}})))
+	assert.Nil(t, Activities.New(2, &index, WithMetadata(ActivityMetadata{Name: "b", Labels: map[string]string{"kind": "flash"}})))
+	activity, _ := Activities.GetActivity(1)
+	assert.False(t, activity.Metadata.CreatedAt.IsZero(), "The creation time should be filled.")
+
+	items, total := Activities.List(nil)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []uint64{1, 2, 3}, []uint64{items[0].ID, items[1].ID, items[2].ID}, "The activities should be ordered by ID.")
+	assert.Equal(t, "a", items[0].Metadata.Name)
+
+	items, total = Activities.List(&ActivityFilter{Labels: map[string]string{"kind": "weekly"}})
+	assert.Equal(t, 2, total)
+	items, total = Activities.List(&ActivityFilter{Labels: map[string]string{"kind": "weekly", "brand": "acme"}})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, uint64(1), items[0].ID)
+
+	items, total = Activities.List(&ActivityFilter{RedisServerIndex: &index})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, uint64(2), items[0].ID)
+
+	_, total = Activities.List(&ActivityFilter{State: ActivityStateRunning})
+	assert.Equal(t, 0, total)
+
+	items, total = Activities.List(&ActivityFilter{Offset: 1, Limit: 1})
+	assert.Equal(t, 3, total, "The total should not be affected by pagination.")
+	assert.Len(t, items, 1)
+	assert.Equal(t, uint64(2), items[0].ID)
+	items, _ = Activities.List(&ActivityFilter{Offset: 3, Limit: 1})
+	assert.Empty(t, items)
+}
@@ -0,0 +1,220 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+// maxActivityPartitions is the maximum number of application partitions of an activity.
+const maxActivityPartitions = 64
+
+var ErrActivityPartitionsInvalid = fmt.Errorf("the number of partitions must not be greater than %d", maxActivityPartitions)
+
+// ActivityPartition represents an application queue of the activity, which is consumed by its own worker coroutine.
+//
+// The application key of partition i is the application key of the activity suffixed with ":i",
+// and the applicants of the applications in it must be located in the applicant key on the same redis server.
+// The seats of all partitions are ranked in the seat key of the activity.
+// If the partition is located in another redis server, the seats are confirmed into a staging key on that server first,
+// and then merged into the seat key of the activity, see Activity.mergeSeats().
+// The scores of the seats come from the clock of the redis server where they are confirmed, so the clocks should be synchronized.
+type ActivityPartition struct {
+	RedisServerIndex uint8 `json:"redis_server_index"`
+}
+
+// ActivityPartitionStatus reports the progress of a partition.
+type ActivityPartitionStatus struct {
+	Index            int        `json:"index"`
+	RedisServerIndex uint8      `json:"redis_server_index"`
+	ApplicationKey   string     `json:"application_key"`
+	Batches          uint64     `json:"batches"`       // The number of batches processed, including the failed ones.
+	Total            uint64     `json:"total"`         // The accumulated number of applications popped.
+	Confirmed        uint64     `json:"confirmed"`     // The accumulated number of seats newly confirmed.
+	Skipped          uint64     `json:"skipped"`       // The accumulated number of applications whose applicant already has a seat.
+	Missing          uint64     `json:"missing"`       // The accumulated number of applications whose applicant does not exist.
+	OverCapacity     uint64     `json:"over_capacity"` // The accumulated number of applications beyond capacity, either waitlisted or dropped.
+	Waitlisted       uint64     `json:"waitlisted"`    // The accumulated number of applicants newly kept in the waitlist.
+	Retried          uint64     `json:"retried"`       // The accumulated number of applications due for retry.
+	Delayed          uint64     `json:"delayed"`       // The accumulated number of applications delayed for retry.
+	Registered       uint64     `json:"registered"`    // The accumulated number of applicants newly registered for the lottery.
+	LastBatchAt      *time.Time `json:"last_batch_at,omitempty"`
+	Backlog          *int64     `json:"backlog,omitempty"` // The number of applications waiting, only reported by Activity.PartitionStatus().
+}
+
+// NewActivityPartitions returns the partitions located in the redis servers with the indexes respectively.
+func NewActivityPartitions(indexes []uint8) []ActivityPartition {
+	if len(indexes) == 0 {
+		return nil
+	}
+	partitions := make([]ActivityPartition, len(indexes))
+	for i, index := range indexes {
+		partitions[i].RedisServerIndex = index
+	}
+	return partitions
+}
+
+// WithPartitions splits the activity into the partitions. If not specified, the activity has a single queue without suffix.
+func WithPartitions(partitions []ActivityPartition) ActivityOption {
+	return func(activity *Activity) {
+		activity.Partitions = append([]ActivityPartition(nil), partitions...)
+	}
+}
+
+// validatePartitions checks the number of partitions.
+func (c *Activity) validatePartitions() error {
+	if len(c.Partitions) > maxActivityPartitions {
+		return ErrActivityPartitionsInvalid
+	}
+	return nil
+}
+
+// partitionCount returns the number of worker coroutines, at least 1.
+func (c *Activity) partitionCount() int {
+	if len(c.Partitions) == 0 {
+		return 1
+	}
+	return len(c.Partitions)
+}
+
+// partitionRedisServerIndex returns the index of the redis server where the partition is located.
+func (c *Activity) partitionRedisServerIndex(partition int) uint8 {
+	if partition < len(c.Partitions) {
+		return c.Partitions[partition].RedisServerIndex
+	}
+	return c.RedisServerIndex
+}
+
+// isRemotePartition determines whether the partition is located in a redis server other than the one of the seats.
+func (c *Activity) isRemotePartition(partition int) bool {
+	return c.partitionRedisServerIndex(partition) != c.RedisServerIndex
+}
+
+// GetRedisServerPartitionApplicationKeyName returns the application key of the partition.
+// If the activity is not partitioned, it is the same as GetRedisServerApplicationKeyName().
+func (c *Activity) GetRedisServerPartitionApplicationKeyName(partition int) string {
+	if len(c.Partitions) == 0 {
+		return c.GetRedisServerApplicationKeyName()
+	}
+	return c.GetRedisServerApplicationKeyName() + ":" + strconv.Itoa(partition)
+}
+
+// GetRedisServerPartitionSeatKeyName returns the key that the partition confirms seats into,
+// which is the staging key on the redis server of the partition if it is remote, or the seat key otherwise.
+func (c *Activity) GetRedisServerPartitionSeatKeyName(partition int) string {
+	if !c.isRemotePartition(partition) {
+		return c.GetRedisServerSeatKeyName()
+	}
+	return c.GetRedisServerSeatKeyName() + ":" + strconv.Itoa(partition)
+}
+
+// partitionsStats keeps the accumulated progress of each partition.
+type partitionsStats struct {
+	lock  sync.RWMutex
+	items []ActivityPartitionStatus
+}
+
+// recordPartition accumulates the result of a batch of the partition. result is nil if the batch failed.
+func (c *Activity) recordPartition(partition int, result *BatchResult) {
+	c.partitionsStats.lock.Lock()
+	defer c.partitionsStats.lock.Unlock()
+	if len(c.partitionsStats.items) < c.partitionCount() {
+		c.partitionsStats.items = append(c.partitionsStats.items, make([]ActivityPartitionStatus, c.partitionCount()-len(c.partitionsStats.items))...)
+	}
+	item := &c.partitionsStats.items[partition]
+	now := time.Now()
+	item.Batches++
+	item.LastBatchAt = &now
+	if result == nil {
+		return
+	}
+	item.Total += result.Total
+	item.Confirmed += result.Confirmed
+	item.Skipped += result.Skipped
+	item.Missing += result.Missing
+	item.OverCapacity += result.OverCapacity
+	item.Waitlisted += result.Waitlisted
+	item.Retried += result.Retried
+	item.Delayed += result.Delayed
+	item.Registered += result.Registered
+}
+
+// PartitionProgress returns the accumulated progress of each partition, without the backlog.
+func (c *Activity) PartitionProgress() []ActivityPartitionStatus {
+	c.partitionsStats.lock.RLock()
+	defer c.partitionsStats.lock.RUnlock()
+	items := make([]ActivityPartitionStatus, c.partitionCount())
+	copy(items, c.partitionsStats.items)
+	for i := range items {
+		items[i].Index = i
+		items[i].RedisServerIndex = c.partitionRedisServerIndex(i)
+		items[i].ApplicationKey = c.GetRedisServerPartitionApplicationKeyName(i)
+	}
+	return items
+}
+
+// PartitionStatus returns the accumulated progress of each partition, as well as the number of applications waiting in it.
+// If the length of any queue fails to be read, the error will be returned.
+func (c *Activity) PartitionStatus(ctx context.Context) ([]ActivityPartitionStatus, error) {
+	items := c.PartitionProgress()
+	for i := range items {
+		client := environment.GlobalRedisClientPool.GetClient(&items[i].RedisServerIndex)
+		backlog, err := client.LLen(ctx, items[i].ApplicationKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		items[i].Backlog = &backlog
+	}
+	return items, nil
+}
+
+// mergeSeats moves the seats confirmed in the staging key of the remote partition into the seat key of the activity,
+// by the redis function "merge_seats", which keeps the scores, skips the applicants who already have a seat,
+// and keeps the ones over capacity in the waitlist if enabled, or drops them.
+// The staged seats are removed after merging. If merging fails, they are kept and merged again in the next batch,
+// and the applicants merged last time are skipped.
+// The result of the batch in the partition is corrected by what happened in merging.
+func (c *Activity) mergeSeats(ctx context.Context, partition int, result *BatchResult) error {
+	index := c.partitionRedisServerIndex(partition)
+	staging := environment.GlobalRedisClientPool.GetClient(&index)
+	stagingKey := c.GetRedisServerPartitionSeatKeyName(partition)
+	seats, err := staging.ZRangeWithScores(ctx, stagingKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	if len(seats) == 0 {
+		result.Confirmed = 0
+		return nil
+	}
+	args := make([]interface{}, 0, 1+len(seats)*2)
+	args = append(args, c.Capacity)
+	members := make([]interface{}, len(seats))
+	for i, seat := range seats {
+		args = append(args, strconv.FormatFloat(seat.Score, 'f', -1, 64), seat.Member)
+		members[i] = seat.Member
+	}
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	keys := []string{c.GetRedisServerSeatKeyName()}
+	if c.IsWaitlistEnabled() {
+		keys = append(keys, c.GetRedisServerWaitlistKeyName())
+	}
+	val, err := client.FCall(ctx, "merge_seats", keys, args...).Uint64Slice()
+	if err != nil {
+		return err
+	}
+	if err := staging.ZRem(ctx, stagingKey, members...).Err(); err != nil {
+		return err
+	}
+	result.Confirmed = val[0]
+	result.Skipped += val[1]
+	result.OverCapacity += val[2]
+	result.SoldOut = val[3] == 1
+	if len(val) > 4 {
+		result.Waitlisted += val[4]
+	}
+	return nil
+}
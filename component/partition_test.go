@@ -0,0 +1,89 @@
+package component
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_PartitionKeyNames(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, 1, activity.partitionCount())
+	assert.Equal(t, activity.GetRedisServerApplicationKeyName(), activity.GetRedisServerPartitionApplicationKeyName(0), "The queue should not be suffixed if not partitioned.")
+	assert.Equal(t, activity.GetRedisServerSeatKeyName(), activity.GetRedisServerPartitionSeatKeyName(0))
+
+	assert.Nil(t, Activities.New(2, nil, WithPartitions(NewActivityPartitions([]uint8{0, 0, 1}))))
+	activity, _ = Activities.GetActivity(2)
+	assert.Equal(t, 3, activity.partitionCount())
+	assert.Equal(t, activity.GetRedisServerApplicationKeyName()+":2", activity.GetRedisServerPartitionApplicationKeyName(2))
+	assert.Equal(t, activity.GetRedisServerSeatKeyName(), activity.GetRedisServerPartitionSeatKeyName(1), "The partition on the same server should confirm seats directly.")
+	assert.Equal(t, activity.GetRedisServerSeatKeyName()+":2", activity.GetRedisServerPartitionSeatKeyName(2), "The remote partition should confirm seats into the staging key.")
+	task := activity.newBatchTask(2)
+	assert.Equal(t, uint8(1), task.RedisServerIndex)
+	assert.Equal(t, uint64(0), task.Capacity)
+
+	keys := activity.GetRedisServerDataKeyNamesByServer()
+	assert.Len(t, keys, 2)
+	assert.Len(t, keys[0], 16)
+	assert.Equal(t, []string{
+		activity.GetRedisServerApplicantKeyName(),
+		activity.GetRedisServerPartitionApplicationKeyName(2),
+		activity.GetRedisServerPartitionDeadLetterKeyName(2),
+		activity.GetRedisServerPartitionRetryKeyName(2),
+		activity.GetRedisServerPartitionSeatKeyName(2),
+	}, keys[1])
+
+	assert.ErrorIs(t, Activities.New(3, nil, WithPartitions(make([]ActivityPartition, maxActivityPartitions+1))), ErrActivityPartitionsInvalid)
+}
+
+// TestWorker_Partitions 测试每个分区由独立的工作协程处理，且分别统计进度。
+func TestWorker_Partitions(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	var lock sync.Mutex
+	keys := make(map[string]int)
+	RegisterProcessor("partitioned", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		lock.Lock()
+		keys[task.ApplicationKey]++
+		lock.Unlock()
+		return &BatchResult{Total: 2, Confirmed: 1, Skipped: 1}, nil
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "partitioned")
+		processorsRWLock.Unlock()
+	}()
+
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("partitioned"), WithInterval(1), WithPartitions(make([]ActivityPartition, 4))))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(time.Millisecond * 50)
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+	<-activity.exitedChan()
+	assert.Equal(t, ActivityStateStopped, activity.State())
+
+	lock.Lock()
+	assert.Len(t, keys, 4)
+	lock.Unlock()
+	status := activity.Status()
+	assert.Len(t, status.Partitions, 4)
+	var batches uint64
+	for i, partition := range status.Partitions {
+		assert.Equal(t, i, partition.Index)
+		assert.Equal(t, activity.GetRedisServerPartitionApplicationKeyName(i), partition.ApplicationKey)
+		assert.Greater(t, partition.Batches, uint64(0))
+		assert.Equal(t, partition.Batches*2, partition.Total)
+		assert.Equal(t, partition.Batches, partition.Confirmed)
+		assert.NotNil(t, partition.LastBatchAt)
+		batches += partition.Batches
+	}
+	assert.Equal(t, status.Batches, batches)
+}
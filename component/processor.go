@@ -0,0 +1,383 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrProcessorNotExist = errors.New("processor not exist")
+
+// The names of the processors registered by default.
+const (
+	ProcessorFunction    = "function"    // The redis function "pop_applications_and_push_into_seats", recommended.
+	ProcessorTransaction = "transaction" // The WATCH/MULTI transaction, which retries on the next tick if the keys are modified.
+	ProcessorNaive       = "naive"       // Pop then push without atomicity, only for demonstration.
+)
+
+// BatchTask describes a batch to be processed.
+type BatchTask struct {
+	Activity         *Activity
+	Partition        int // The index of the partition, 0 if the activity is not partitioned.
+	RedisServerIndex uint8
+	Batch            uint16 // The maximum number of applications to be popped.
+	Capacity         uint64 // The number of seats, 0 means unlimited.
+	ApplicationKey   string
+	ApplicantKey     string
+	SeatKey          string
+	DeadLetterKey    string // The applications whose applicant is missing are kept here.
+	WaitlistKey      string // The applicants over capacity are kept here, empty if the waitlist is disabled.
+	RetryKey         string // The applications whose applicant is missing are retried from here, empty if retrying is disabled.
+	Retry            ActivityRetry
+	SeatCountKey     string // The number of seats of each applicant is tracked here, empty if the quota is 1.
+	Quota            uint16 // The maximum number of seats of each applicant.
+	RegistrationKey  string // The applicants are registered here instead of being seated, empty unless the seats are allocated by lottery.
+}
+
+// newBatchTask returns the task of the next batch of the partition according to the current settings of the activity.
+// The task of a remote partition confirms seats into its staging key without capacity,
+// and the capacity is checked when merging, see Activity.mergeSeats().
+func (c *Activity) newBatchTask(partition int) *BatchTask {
+	task := &BatchTask{
+		Activity:         c,
+		Partition:        partition,
+		RedisServerIndex: c.partitionRedisServerIndex(partition),
+		Batch:            c.GetEffectiveBatch(),
+		Capacity:         c.Capacity,
+		ApplicationKey:   c.GetRedisServerPartitionApplicationKeyName(partition),
+		ApplicantKey:     c.GetRedisServerApplicantKeyName(),
+		SeatKey:          c.GetRedisServerPartitionSeatKeyName(partition),
+		DeadLetterKey:    c.GetRedisServerPartitionDeadLetterKeyName(partition),
+		Quota:            c.GetQuota(),
+	}
+	if task.Quota > 1 {
+		task.SeatCountKey = c.GetRedisServerSeatCountKeyName()
+	}
+	if c.isRemotePartition(partition) {
+		task.Capacity = 0
+	} else if c.IsLottery() {
+		// The capacity is checked when drawing, see Activity.Draw().
+		task.Capacity = 0
+		task.RegistrationKey = c.GetRedisServerRegistrationKeyName()
+	} else if c.IsWaitlistEnabled() {
+		task.WaitlistKey = c.GetRedisServerWaitlistKeyName()
+	}
+	if retry := c.getRetry(); retry.Enabled() {
+		task.RetryKey = c.GetRedisServerPartitionRetryKeyName(partition)
+		task.Retry = *retry
+	}
+	return task
+}
+
+func (t *BatchTask) client() *redis.Client {
+	return environment.GlobalRedisClientPool.GetClient(&t.RedisServerIndex)
+}
+
+// BatchResult reports what happened to a batch.
+type BatchResult struct {
+	Total        uint64        `json:"total"`         // The number of applications popped.
+	Confirmed    uint64        `json:"confirmed"`     // The number of seats newly confirmed.
+	Skipped      uint64        `json:"skipped"`       // The number of applications whose applicant already has a seat, has reached the quota, or has been registered.
+	Missing      uint64        `json:"missing"`       // The number of applications whose applicant does not exist, which are moved into the dead-letter list.
+	OverCapacity uint64        `json:"over_capacity"` // The number of applications beyond capacity, either waitlisted or dropped.
+	Waitlisted   uint64        `json:"waitlisted"`    // The number of applicants newly kept in the waitlist.
+	Retried      uint64        `json:"retried"`       // The number of applications due for retry, which are not counted in Total.
+	Delayed      uint64        `json:"delayed"`       // The number of applications whose applicant is missing, delayed for retry.
+	Registered   uint64        `json:"registered"`    // The number of applicants newly registered for the lottery.
+	SoldOut      bool          `json:"sold_out"`      // Whether all seats have been confirmed.
+	Elapsed      time.Duration `json:"elapsed"`
+}
+
+// Processor processes a batch of applications.
+// An error should be returned instead of panicking, and the worker will be stopped with it.
+type Processor interface {
+	Process(ctx context.Context, task *BatchTask) (*BatchResult, error)
+}
+
+// ProcessorFunc is an adapter to allow the use of ordinary functions as processors.
+type ProcessorFunc func(ctx context.Context, task *BatchTask) (*BatchResult, error)
+
+func (f ProcessorFunc) Process(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	return f(ctx, task)
+}
+
+var processors = map[string]Processor{
+	ProcessorFunction:    &functionProcessor{},
+	ProcessorTransaction: &transactionProcessor{},
+	ProcessorNaive:       &naiveProcessor{},
+}
+var processorsRWLock sync.RWMutex
+
+// RegisterProcessor registers the processor with the name, replacing the existing one with the same name.
+func RegisterProcessor(name string, processor Processor) {
+	processorsRWLock.Lock()
+	defer processorsRWLock.Unlock()
+	processors[name] = processor
+}
+
+// GetProcessor returns the processor registered with the name.
+// If the processor does not exist, an ErrProcessorNotExist error will be returned.
+func GetProcessor(name string) (Processor, error) {
+	processorsRWLock.RLock()
+	defer processorsRWLock.RUnlock()
+	processor, existed := processors[name]
+	if !existed {
+		return nil, ErrProcessorNotExist
+	}
+	return processor, nil
+}
+
+// ProcessorNames returns the names of all registered processors, ordered by name.
+func ProcessorNames() []string {
+	processorsRWLock.RLock()
+	defer processorsRWLock.RUnlock()
+	names := make([]string, 0, len(processors))
+	for name := range processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProcessor specifies the name of the processor of the activity.
+// If not specified, EnvActivity.Processor is used.
+func WithProcessor(name string) ActivityOption {
+	return func(activity *Activity) {
+		activity.Processor = name
+	}
+}
+
+// defaultActivityProcessor returns the processor configured, or the default one if the environment has not been loaded.
+func defaultActivityProcessor() string {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Processor != nil {
+		return *GlobalEnv.Activity.Processor
+	}
+	return *(&EnvActivity{}).GetProcessorDefault()
+}
+
+// functionProcessor takes a batch from the application queue and sends it into the seat for confirmation.
+//
+// This processor relies on the redis function "pop_applications_and_push_into_seats".
+// Therefore, before using it, you need to prepare relevant redis functions.
+// Since the batch is processed by the Lua script of redis,
+// this operation can avoid failure due to changes in related keys,
+// and it will not cause out-of-sequence that may be caused by simultaneous execution of multiple worker processes,
+// and it can also shorten the interaction time with the client.
+type functionProcessor struct{}
+
+func (p *functionProcessor) Process(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	tmStart := time.Now()
+	// The optional keys are passed as empty strings if disabled.
+	val, err := task.client().FCall(ctx, "pop_applications_and_push_into_seats", []string{
+		task.ApplicationKey,
+		task.ApplicantKey,
+		task.SeatKey,
+		task.DeadLetterKey,
+		task.WaitlistKey,
+		task.RetryKey,
+		task.SeatCountKey,
+		task.RegistrationKey,
+	}, task.Batch, task.Capacity, task.Retry.Delay, task.Retry.Attempts, task.Quota).Uint64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &BatchResult{
+		Total:        val[0],
+		Confirmed:    val[1],
+		Skipped:      val[2],
+		Missing:      val[3],
+		OverCapacity: val[4],
+		SoldOut:      val[5] == 1,
+		Elapsed:      time.Since(tmStart),
+	}
+	if len(val) > 6 {
+		result.Waitlisted = val[6]
+	}
+	if len(val) > 8 {
+		result.Retried = val[7]
+		result.Delayed = val[8]
+	}
+	if len(val) > 9 {
+		result.Registered = val[9]
+	}
+	return result, nil
+}
+
+// transactionProcessor 从申请队列中取出一批，再送入席位。
+// 整个过程在监控“申请”、“申请人”和“席位”的事务中进行。但依然不推荐此做法，除非可以保证处理进程只有一个，且处理期间“席位”没有发生修改，
+// 否则，因“存”时频繁监控到相关键已发生修改而失败。失败时本批次不做任何修改，留待下次处理。
+type transactionProcessor struct{}
+
+func (p *transactionProcessor) Process(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	tmStart := time.Now()
+	var result BatchResult
+	txf := func(tx *redis.Tx) error {
+		result = BatchResult{}
+		seats, err := tx.ZCard(ctx, task.SeatKey).Result()
+		if err != nil {
+			return err
+		}
+		if task.Capacity > 0 && uint64(seats) >= task.Capacity && task.WaitlistKey == "" {
+			result.SoldOut = true
+			return nil
+		}
+		// 先读取一批“申请”，在事务中再从队列中移除。
+		applications, err := tx.LRange(ctx, task.ApplicationKey, 0, int64(task.Batch)-1).Result()
+		if err != nil || len(applications) == 0 {
+			return err
+		}
+		applicants, err := tx.HMGet(ctx, task.ApplicantKey, applications...).Result()
+		if err != nil {
+			return err
+		}
+		members := make([]redis.Z, 0, len(applications))
+		waitlist := make([]redis.Z, 0)
+		var waitlisted *redis.IntCmd
+		confirmed := make(map[string]bool)
+		deadLetters := make([]interface{}, 0)
+		for i, v := range applicants {
+			applicant, ok := v.(string)
+			// 如果“申请人”不存在，则将“申请”移入死信队列。
+			if !ok {
+				result.Missing++
+				deadLetters = append(deadLetters, formatDeadLetter(time.Now(), applications[i]))
+				continue
+			}
+			if confirmed[applicant] {
+				result.Skipped++
+				continue
+			}
+			// 已有“席位”的“申请人”不会更新。
+			if err := tx.ZScore(ctx, task.SeatKey, applicant).Err(); err == nil {
+				result.Skipped++
+				continue
+			} else if err != redis.Nil {
+				return err
+			}
+			if task.Capacity > 0 && uint64(seats) >= task.Capacity {
+				result.OverCapacity++
+				// 超出容量的“申请人”按申请顺序列入候补名单。
+				if task.WaitlistKey != "" {
+					waitlist = append(waitlist, redis.Z{Score: float64(time.Now().UnixMicro()), Member: applicant})
+				}
+				continue
+			}
+			confirmed[applicant] = true
+			seats++
+			members = append(members, redis.Z{Score: float64(time.Now().UnixMicro()), Member: applicant})
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.LTrim(ctx, task.ApplicationKey, int64(len(applications)), -1)
+			if len(members) > 0 {
+				pipe.ZAddNX(ctx, task.SeatKey, members...)
+			}
+			if len(waitlist) > 0 {
+				waitlisted = pipe.ZAddNX(ctx, task.WaitlistKey, waitlist...)
+			}
+			if len(deadLetters) > 0 {
+				pipe.RPush(ctx, task.DeadLetterKey, deadLetters...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.Total = uint64(len(applications))
+		result.Confirmed = uint64(len(members))
+		if waitlisted != nil {
+			result.Waitlisted = uint64(waitlisted.Val())
+		}
+		result.SoldOut = task.Capacity > 0 && uint64(seats) >= task.Capacity
+		return nil
+	}
+	err := task.client().Watch(ctx, txf, task.ApplicationKey, task.ApplicantKey, task.SeatKey)
+	if err == redis.TxFailedErr {
+		log.Printf("[ActivityID: %d]: %s, retry on the next tick.\n", task.Activity.ID, err.Error())
+		return &BatchResult{Elapsed: time.Since(tmStart)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	result.Elapsed = time.Since(tmStart)
+	return &result, nil
+}
+
+// naiveProcessor 从申请队列中取出一批，再逐个送入席位。
+// 但强烈不推荐此做法，除非对性能要求不高，且可以保证处理进程只有一个，否则，因“取”和“存”之间并非原子操作而导致乱序。
+// 此处仅做流程展示，并非用在实际应用中。
+type naiveProcessor struct{}
+
+func (p *naiveProcessor) Process(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	tmStart := time.Now()
+	client := task.client()
+	result := BatchResult{}
+	seats, err := client.ZCard(ctx, task.SeatKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if task.Capacity > 0 && uint64(seats) >= task.Capacity && task.WaitlistKey == "" {
+		result.SoldOut = true
+		result.Elapsed = time.Since(tmStart)
+		return &result, nil
+	}
+	// 从“申请”队列获取一批“申请”
+	applications, err := client.LPopCount(ctx, task.ApplicationKey, int(task.Batch)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	result.Total = uint64(len(applications))
+	// 将“申请”对应的“申请人”送入“席位”中。已经存在的申请则忽略，未存在的按顺序依次列在最后。
+	for _, application := range applications {
+		applicant, err := client.HGet(ctx, task.ApplicantKey, application).Result()
+		if err == redis.Nil {
+			result.Missing++
+			// 将“申请人”不存在的“申请”移入死信队列。
+			if err := client.RPush(ctx, task.DeadLetterKey, formatDeadLetter(time.Now(), application)).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if task.Capacity > 0 && uint64(seats) >= task.Capacity {
+			if client.ZScore(ctx, task.SeatKey, applicant).Err() == nil {
+				result.Skipped++
+				continue
+			}
+			result.OverCapacity++
+			if task.WaitlistKey == "" {
+				continue
+			}
+			waitlisted, err := client.ZAddNX(ctx, task.WaitlistKey, redis.Z{
+				Score:  float64(time.Now().UnixMicro()),
+				Member: applicant,
+			}).Result()
+			if err != nil {
+				return nil, err
+			}
+			result.Waitlisted += uint64(waitlisted)
+			continue
+		}
+		added, err := client.ZAddNX(ctx, task.SeatKey, redis.Z{
+			Score:  float64(time.Now().UnixMicro()),
+			Member: applicant,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if added == 1 {
+			result.Confirmed++
+			seats++
+		} else {
+			result.Skipped++
+		}
+	}
+	result.SoldOut = task.Capacity > 0 && uint64(seats) >= task.Capacity
+	result.Elapsed = time.Since(tmStart)
+	return &result, nil
+}
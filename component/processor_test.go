@@ -0,0 +1,59 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProcessor(t *testing.T) {
+	assert.Equal(t, []string{ProcessorFunction, ProcessorNaive, ProcessorTransaction}, ProcessorNames())
+	for _, name := range ProcessorNames() {
+		processor, err := GetProcessor(name)
+		assert.Nil(t, err)
+		assert.NotNil(t, processor)
+	}
+	_, err := GetProcessor("unknown")
+	assert.ErrorIs(t, err, ErrProcessorNotExist)
+}
+
+func TestActivityPool_NewWithProcessor(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithProcessor("unknown")), ErrProcessorNotExist)
+	assert.Nil(t, Activities.New(1, nil))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, defaultActivityProcessor(), activity.Processor)
+	assert.Nil(t, Activities.New(2, nil, WithProcessor(ProcessorTransaction)))
+	activity, _ = Activities.GetActivity(2)
+	assert.Equal(t, ProcessorTransaction, activity.Status().Processor)
+}
+
+// TestWorker_ProcessorFailed 测试处理器返回错误时活动以该错误停止，并置为失败。
+func TestWorker_ProcessorFailed(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	errFailed := errors.New("failed")
+	RegisterProcessor("failing", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		return nil, errFailed
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "failing")
+		processorsRWLock.Unlock()
+	}()
+
+	interval := uint16(1)
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("failing"), WithInterval(interval)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+	transitions := activity.StateTransitions()
+	assert.Equal(t, errFailed.Error(), transitions[len(transitions)-1].Cause)
+}
@@ -0,0 +1,209 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrActivityPurgeFailed = errors.New("activity removed, but failed to purge")
+var ErrWorkerNotExited = errors.New("the worker has not exited")
+
+// ActivityPurgeOptions specifies how the data of the activity located in redis are purged.
+type ActivityPurgeOptions struct {
+	Archive bool // Copy the seats to an archive key before purging.
+}
+
+// ActivityPurgeResult reports what were purged.
+type ActivityPurgeResult struct {
+	Keys        int64    `json:"keys"`                   // The number of keys unlinked.
+	Members     int64    `json:"members"`                // The total number of members in the keys unlinked.
+	ArchiveKeys []string `json:"archive_keys,omitempty"` // The keys that the seats are archived to.
+}
+
+// merge accumulates another result into this one.
+func (r *ActivityPurgeResult) merge(other *ActivityPurgeResult) {
+	if other == nil {
+		return
+	}
+	r.Keys += other.Keys
+	r.Members += other.Members
+	r.ArchiveKeys = append(r.ArchiveKeys, other.ArchiveKeys...)
+}
+
+func (c *Activity) GetRedisServerSeatArchiveKeyName(tm time.Time) string {
+	return fmt.Sprintf("%s%d_%d", c.GetKeyPrefix().SeatArchive, c.ID, tm.Unix())
+}
+
+// GetRedisServerDataKeyNames returns the names of all keys that hold the data of the activity
+// in the redis server of the activity, see GetRedisServerDataKeyNamesByServer() for the partitions located elsewhere.
+func (c *Activity) GetRedisServerDataKeyNames() []string {
+	return c.GetRedisServerDataKeyNamesByServer()[c.RedisServerIndex]
+}
+
+// GetRedisServerDataKeyNamesByServer returns the names of all keys that hold the data of the activity,
+// grouped by the index of the redis server where they are located.
+func (c *Activity) GetRedisServerDataKeyNamesByServer() map[uint8][]string {
+	keys := map[uint8][]string{
+		c.RedisServerIndex: {
+			c.GetRedisServerApplicationKeyName(),
+			c.GetRedisServerApplicantKeyName(),
+			c.GetRedisServerSeatKeyName(),
+			c.GetRedisServerWaitlistKeyName(),
+			c.GetRedisServerRevocationKeyName(),
+			c.GetRedisServerDeadLetterKeyName(),
+			c.GetRedisServerRetryKeyName(),
+			c.GetRedisServerSeatCountKeyName(),
+			c.GetRedisServerRegistrationKeyName(),
+			c.GetRedisServerDrawKeyName(),
+		},
+	}
+	for i := range c.Partitions {
+		index := c.partitionRedisServerIndex(i)
+		if _, existed := keys[index]; !existed {
+			keys[index] = []string{c.GetRedisServerApplicantKeyName()}
+		}
+		keys[index] = append(keys[index], c.GetRedisServerPartitionApplicationKeyName(i), c.GetRedisServerPartitionDeadLetterKeyName(i),
+			c.GetRedisServerPartitionRetryKeyName(i))
+		if c.isRemotePartition(i) {
+			keys[index] = append(keys[index], c.GetRedisServerPartitionSeatKeyName(i))
+		}
+	}
+	return keys
+}
+
+// countMembers returns the number of members of the key according to its type, 0 if the key does not exist.
+func countMembers(ctx context.Context, client *redis.Client, key string) (int64, error) {
+	kind, err := client.Type(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "list":
+		return client.LLen(ctx, key).Result()
+	case "hash":
+		return client.HLen(ctx, key).Result()
+	case "zset":
+		return client.ZCard(ctx, key).Result()
+	case "set":
+		return client.SCard(ctx, key).Result()
+	}
+	return 0, nil
+}
+
+// Purge deletes the data of the activity located in redis.
+//
+// The keys are deleted with UNLINK, so that large keys are reclaimed in the background without blocking redis.
+// If options.Archive is true, the seats are copied to an archive key first, and nothing is deleted if copying fails.
+// The keys of the partitions located in other redis servers are purged as well.
+// The worker should be stopped before purging, otherwise the data may be written again.
+func (c *Activity) Purge(ctx context.Context, options *ActivityPurgeOptions) (*ActivityPurgeResult, error) {
+	result := ActivityPurgeResult{}
+	if options != nil && options.Archive {
+		client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+		seatKey := c.GetRedisServerSeatKeyName()
+		archiveKey := c.GetRedisServerSeatArchiveKeyName(time.Now())
+		copied, err := client.Copy(ctx, seatKey, archiveKey, client.Options().DB, false).Result()
+		if err != nil {
+			return nil, err
+		}
+		if copied == 1 {
+			result.ArchiveKeys = append(result.ArchiveKeys, archiveKey)
+		}
+	}
+	for index, keys := range c.GetRedisServerDataKeyNamesByServer() {
+		index := index
+		client := environment.GlobalRedisClientPool.GetClient(&index)
+		for _, key := range keys {
+			members, err := countMembers(ctx, client, key)
+			if err != nil {
+				return nil, err
+			}
+			result.Members += members
+		}
+		unlinked, err := client.Unlink(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		result.Keys += unlinked
+	}
+	return &result, nil
+}
+
+// exitedChan returns the channel that is closed when the current worker coroutine exits, nil if it has never started.
+func (c *Activity) exitedChan() <-chan struct{} {
+	c.contextCancelFuncRWLock.RLock()
+	defer c.contextCancelFuncRWLock.RUnlock()
+	return c.exited
+}
+
+// waitExited waits for the current worker coroutine to exit until the context is done,
+// since the batch in progress is not cut off when the worker is stopped, and may still write to the keys.
+// If the worker has not exited when the context is done, an ErrWorkerNotExited error will be returned.
+func (c *Activity) waitExited(ctx context.Context) error {
+	exited := c.exitedChan()
+	if exited == nil {
+		return nil
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return ErrWorkerNotExited
+	}
+}
+
+// RemoveAndPurge removes the activity with specified ID, see Remove(), and then purges its data, see Activity.Purge().
+// The data are purged after the worker exits, and the context bounds the waiting as well.
+// If the activity fails to be removed, nothing is purged.
+// If the activity is removed but fails to be purged, an ErrActivityPurgeFailed error wrapping the cause will be returned,
+// including an ErrWorkerNotExited error if the worker has not exited when the context is done.
+func (a *ActivityPool) RemoveAndPurge(ctx context.Context, id uint64, stopBeforeRemoving bool, options *ActivityPurgeOptions) (*ActivityPurgeResult, error) {
+	activity, err := a.GetActivity(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Remove(id, stopBeforeRemoving); err != nil {
+		return nil, err
+	}
+	if err := activity.waitExited(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrActivityPurgeFailed, err)
+	}
+	result, err := activity.Purge(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrActivityPurgeFailed, err.Error())
+	}
+	return result, nil
+}
+
+// RemoveAllAndPurge removes all activities, see RemoveAll(), and then purges their data after their workers exit, see Activity.Purge().
+// Returns the number of workers stopped, and the accumulated result of purging.
+// The activities that fail to be purged, including those whose worker has not exited when the context is done, are only logged.
+func (a *ActivityPool) RemoveAllAndPurge(ctx context.Context, options *ActivityPurgeOptions) (int, *ActivityPurgeResult) {
+	a.ActivitiesRWLock.RLock()
+	activities := make([]*Activity, 0, len(a.Activities))
+	for _, v := range a.Activities {
+		activities = append(activities, v)
+	}
+	a.ActivitiesRWLock.RUnlock()
+	count := a.RemoveAll()
+	result := ActivityPurgeResult{}
+	for _, v := range activities {
+		if err := v.waitExited(ctx); err != nil {
+			log.Printf("[ActivityID: %d] failed to purge: %s\n", v.ID, err.Error())
+			continue
+		}
+		purged, err := v.Purge(ctx, options)
+		if err != nil {
+			log.Printf("[ActivityID: %d] failed to purge: %s\n", v.ID, err.Error())
+			continue
+		}
+		result.merge(purged)
+	}
+	return count, &result
+}
@@ -0,0 +1,33 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityPool_RemoveAndPurgeWaitsForWorker(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.waitExited(context.Background()), "The worker never started should not be waited.")
+
+	// The worker is still in the middle of its batch.
+	exited := make(chan struct{})
+	activity.exited = exited
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err := Activities.RemoveAndPurge(ctx, 1, true, nil)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrActivityPurgeFailed)
+	assert.ErrorIs(t, err, ErrWorkerNotExited, "Nothing should be purged before the worker exits.")
+	_, err = Activities.GetActivity(1)
+	assert.ErrorIs(t, err, ErrActivityNotExist)
+
+	close(exited)
+	assert.Nil(t, activity.waitExited(context.Background()))
+}
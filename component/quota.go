@@ -0,0 +1,144 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrActivityQuotaInvalid = errors.New("the quota must be greater than 0, and greater than 1 only with the function processor and all partitions located in the redis server of the seats")
+
+// ActivitySeat represents a seat of an applicant.
+type ActivitySeat struct {
+	Member   string    `json:"member"`   // The member in the seat key, see WithQuota().
+	Position int64     `json:"position"` // The rank among all seats, starting from 1.
+	Time     time.Time `json:"time"`     // When the application was popped.
+}
+
+// ActivityApplicantSeats represents the seats of an applicant.
+type ActivityApplicantSeats struct {
+	Applicant string         `json:"applicant"`
+	Quota     uint16         `json:"quota"`
+	Seats     []ActivitySeat `json:"seats"` // Ordered by the number of the seat, empty if the applicant does not have a seat.
+}
+
+// WithQuota specifies the maximum number of seats of each applicant.
+// If not specified, EnvActivity.Quota is used.
+//
+// If the quota is greater than 1, the applicant is admitted until the quota is reached,
+// and the number of seats of each applicant is tracked in the seat count key.
+// The first seat of the applicant is the applicant itself in the seat key, and the n-th seat is the applicant suffixed with "#n",
+// so that the seat key still counts all seats against the capacity.
+// The seat revoked is always the latest one of the applicant, see Activity.RevokeSeat().
+// The quota is only supported by the function processor, see ProcessorFunction,
+// and it cannot be greater than 1 if any partition is located in another redis server, see WithPartitions(),
+// or in dry-run mode, see WithDryRun().
+func WithQuota(quota uint16) ActivityOption {
+	return func(activity *Activity) {
+		activity.Quota = quota
+	}
+}
+
+// defaultActivityQuota returns the quota configured, or the default value if the environment has not been loaded.
+func defaultActivityQuota() uint16 {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Quota != nil {
+		return *GlobalEnv.Activity.Quota
+	}
+	return *(&EnvActivity{}).GetQuotaDefault()
+}
+
+// GetQuota returns the maximum number of seats of each applicant.
+func (c *Activity) GetQuota() uint16 {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.Quota
+}
+
+// validateQuota checks the quota against the processor and the partitions.
+func (c *Activity) validateQuota(quota uint16) error {
+	if quota == 0 {
+		return ErrActivityQuotaInvalid
+	}
+	if quota == 1 {
+		return nil
+	}
+	if c.IsLottery() {
+		return ErrActivityLotteryInvalid
+	}
+	if c.Processor != ProcessorFunction {
+		return ErrActivityQuotaInvalid
+	}
+	for i := range c.Partitions {
+		if c.isRemotePartition(i) {
+			return ErrActivityQuotaInvalid
+		}
+	}
+	return nil
+}
+
+func (c *Activity) GetRedisServerSeatCountKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().SeatCount, c.ID)
+}
+
+// seatMember returns the member of the n-th seat of the applicant in the seat key.
+func seatMember(applicant string, n int64) string {
+	if n <= 1 {
+		return applicant
+	}
+	return applicant + "#" + strconv.FormatInt(n, 10)
+}
+
+// GetApplicantSeats returns the seats of the applicant.
+// The applicant who is absent from the seat count key has a seat if the applicant itself is in the seat key.
+func (c *Activity) GetApplicantSeats(ctx context.Context, applicant string) (*ActivityApplicantSeats, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	seatKey := c.GetRedisServerSeatKeyName()
+	count, err := client.HGet(ctx, c.GetRedisServerSeatCountKeyName(), applicant).Int64()
+	if err == redis.Nil {
+		count = 1
+	} else if err != nil {
+		return nil, err
+	}
+	scores := make([]*redis.FloatCmd, count)
+	ranks := make([]*redis.IntCmd, count)
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := int64(0); i < count; i++ {
+			scores[i] = pipe.ZScore(ctx, seatKey, seatMember(applicant, i+1))
+			ranks[i] = pipe.ZRank(ctx, seatKey, seatMember(applicant, i+1))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	seats := ActivityApplicantSeats{
+		Applicant: applicant,
+		Quota:     c.GetQuota(),
+		Seats:     make([]ActivitySeat, 0, count),
+	}
+	for i := int64(0); i < count; i++ {
+		score, err := scores[i].Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		rank, err := ranks[i].Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		seats.Seats = append(seats.Seats, ActivitySeat{
+			Member:   seatMember(applicant, i+1),
+			Position: rank + 1,
+			Time:     time.UnixMicro(int64(score)),
+		})
+	}
+	return &seats, nil
+}
@@ -0,0 +1,46 @@
+package component
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_Quota(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Equal(t, "a", seatMember("a", 1))
+	assert.Equal(t, "a#3", seatMember("a", 3))
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithQuota(2), WithPartitions(NewActivityPartitions([]uint8{0, 1}))), ErrActivityQuotaInvalid)
+	assert.Nil(t, Activities.New(1, nil, WithPartitions(NewActivityPartitions([]uint8{0, 0}))))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, uint16(1), activity.Status().Quota)
+	task := activity.newBatchTask(1)
+	assert.Equal(t, uint16(1), task.Quota)
+	assert.Empty(t, task.SeatCountKey, "The seats should not be counted if the quota is 1.")
+
+	quota := uint16(0)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Quota: &quota}), ErrActivityQuotaInvalid)
+	quota = 3
+	assert.Nil(t, activity.Update(&ActivitySettings{Quota: &quota}))
+	assert.Equal(t, uint16(3), activity.Status().Quota)
+	assert.Equal(t, uint16(3), activity.record().Quota)
+	task = activity.newBatchTask(1)
+	assert.Equal(t, uint16(3), task.Quota)
+	assert.Equal(t, "activity_seat_count_1", task.SeatCountKey)
+
+	assert.Nil(t, Activities.New(2, nil, WithPartitions(NewActivityPartitions([]uint8{0, 1}))))
+	activity, _ = Activities.GetActivity(2)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Quota: &quota}), ErrActivityQuotaInvalid, "The remote partition cannot count the seats.")
+
+	assert.ErrorIs(t, Activities.New(3, nil, WithQuota(2), WithProcessor(ProcessorTransaction)), ErrActivityQuotaInvalid)
+	assert.Nil(t, Activities.New(3, nil, WithProcessor(ProcessorNaive)))
+	activity, _ = Activities.GetActivity(3)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Quota: &quota}), ErrActivityQuotaInvalid, "Only the function processor counts the seats.")
+
+	assert.ErrorIs(t, (&ActivityTemplate{Name: "multi", Quota: 2, Partitions: NewActivityPartitions([]uint8{1})}).Validate(), ErrActivityQuotaInvalid)
+	assert.ErrorIs(t, (&ActivityTemplate{Name: "multi", Quota: 2, Processor: ProcessorTransaction}).Validate(), ErrActivityQuotaInvalid)
+	assert.Nil(t, (&ActivityTemplate{Name: "multi", Quota: 2}).Validate())
+}
@@ -0,0 +1,253 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+// ActivityRecord represents the persisted definition of an activity and its desired run state.
+type ActivityRecord struct {
+	ID               uint64                           `json:"id"`
+	RedisServerIndex uint8                            `json:"redis_server_index"`
+	Batch            uint16                           `json:"batch"`
+	Interval         uint16                           `json:"interval"`
+	Mode             ActivityMode                     `json:"mode,omitempty"`
+	AdaptiveBatch    *ActivityAdaptiveBatch           `json:"adaptive_batch,omitempty"`
+	RestartPolicy    *ActivityRestartPolicy           `json:"restart_policy,omitempty"`
+	Retry            *ActivityRetry                   `json:"retry,omitempty"`
+	Partitions       []ActivityPartition              `json:"partitions,omitempty"`
+	Capacity         uint64                           `json:"capacity"`
+	Weight           uint16                           `json:"weight,omitempty"`
+	DryRun           bool                             `json:"dry_run,omitempty"`
+	Waitlist         bool                             `json:"waitlist,omitempty"`
+	Quota            uint16                           `json:"quota,omitempty"`
+	Allocation       ActivityAllocation               `json:"allocation,omitempty"`
+	StartAt          *time.Time                       `json:"start_at,omitempty"`
+	EndAt            *time.Time                       `json:"end_at,omitempty"`
+	Metadata         ActivityMetadata                 `json:"metadata"`
+	KeyPrefix        *EnvActivityRedisServerKeyPrefix `json:"key_prefix,omitempty"`
+	Processor        string                           `json:"processor,omitempty"`
+	Running          bool                             `json:"running"`          // Whether the worker should be running after restoring.
+	Paused           bool                             `json:"paused,omitempty"` // Whether the worker should be paused after restoring, kept when the process exits.
+	State            ActivityState                    `json:"state"`            // The last state, used to restore the activity that is not running.
+}
+
+// ActivityRegistry persists activity records so that the activity pool can be rebuilt after restarting.
+type ActivityRegistry interface {
+	// Save creates or replaces the record with the same activity ID.
+	Save(ctx context.Context, record *ActivityRecord) error
+	// Delete removes the record of the specified activity ID. Deleting a non-existent record is not an error.
+	Delete(ctx context.Context, id uint64) error
+	// Load returns all records.
+	Load(ctx context.Context) ([]ActivityRecord, error)
+}
+
+// RedisActivityRegistry stores the activity records in a redis hash, the field is the activity ID,
+// and the value is the JSON-encoded record.
+// It also stores the activity templates in another hash, see ActivityTemplateRegistry.
+type RedisActivityRegistry struct {
+	RedisServerIndex uint8
+	Key              string
+	TemplateKey      string
+}
+
+// NewRedisActivityRegistry creates a registry with the specified configuration.
+// If env is nil, the default configuration is used, see EnvActivity.GetRegistryDefault().
+func NewRedisActivityRegistry(env *EnvActivityRegistry) *RedisActivityRegistry {
+	if env == nil {
+		env = (&EnvActivity{}).GetRegistryDefault()
+	}
+	return &RedisActivityRegistry{
+		RedisServerIndex: env.RedisServerIndex,
+		Key:              env.Key,
+		TemplateKey:      env.TemplateKey,
+	}
+}
+
+func (r *RedisActivityRegistry) Save(ctx context.Context, record *ActivityRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	return client.HSet(ctx, r.Key, strconv.FormatUint(record.ID, 10), value).Err()
+}
+
+func (r *RedisActivityRegistry) Delete(ctx context.Context, id uint64) error {
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	return client.HDel(ctx, r.Key, strconv.FormatUint(id, 10)).Err()
+}
+
+func (r *RedisActivityRegistry) Load(ctx context.Context) ([]ActivityRecord, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	values, err := client.HGetAll(ctx, r.Key).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ActivityRecord, 0, len(values))
+	for field, value := range values {
+		var record ActivityRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			log.Printf("[Registry] record %s skipped: %s\n", field, err.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SetRegistry specifies the registry that the activity pool writes through to.
+// If the registry is nil, the activity pool only lives in memory.
+func (a *ActivityPool) SetRegistry(registry ActivityRegistry) {
+	a.ActivitiesRWLock.Lock()
+	defer a.ActivitiesRWLock.Unlock()
+	a.registry = registry
+}
+
+// activityRegistryTimeout bounds each write to the registry, so that a hanging registry cannot block the callers forever.
+const activityRegistryTimeout = 3 * time.Second
+
+// save writes the record to the registry, if any.
+func (a *ActivityPool) save(record *ActivityRecord) error {
+	if a == nil || a.registry == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), activityRegistryTimeout)
+	defer cancel()
+	return a.registry.Save(ctx, record)
+}
+
+// Restore rebuilds the activity pool from the registry, and restarts the workers that were running.
+// The workers that were paused are restarted and paused again.
+// Activities that already exist in the pool are skipped.
+// Returns the number of activities restored.
+func (a *ActivityPool) Restore(ctx context.Context) (int, error) {
+	if a.registry == nil {
+		return 0, nil
+	}
+	records, err := a.registry.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, record := range records {
+		activity := newActivityFromRecord(&record)
+		a.ActivitiesRWLock.Lock()
+		if _, existed := a.Activities[record.ID]; existed {
+			a.ActivitiesRWLock.Unlock()
+			continue
+		}
+		activity.pool = a
+		a.Activities[record.ID] = activity
+		a.ActivitiesRWLock.Unlock()
+		count++
+		if !record.Running {
+			continue
+		}
+		if err := activity.Start(context.Background()); err != nil {
+			log.Printf("[ActivityID: %d] failed to restart the worker: %s\n", record.ID, err.Error())
+			continue
+		}
+		if record.Paused || record.State == ActivityStatePaused {
+			if err := activity.Pause(); err != nil {
+				log.Printf("[ActivityID: %d] failed to pause the worker: %s\n", record.ID, err.Error())
+			}
+		}
+	}
+	return count, nil
+}
+
+// record returns the persisted form of the activity.
+func (c *Activity) record() *ActivityRecord {
+	return &ActivityRecord{
+		ID:               c.ID,
+		RedisServerIndex: c.RedisServerIndex,
+		Batch:            c.Batch,
+		Interval:         c.Interval,
+		Mode:             c.Mode,
+		AdaptiveBatch:    c.AdaptiveBatch,
+		RestartPolicy:    c.RestartPolicy,
+		Retry:            c.Retry,
+		Partitions:       c.Partitions,
+		Capacity:         c.Capacity,
+		Weight:           c.Weight,
+		DryRun:           c.DryRun,
+		Waitlist:         c.Waitlist,
+		Quota:            c.Quota,
+		Allocation:       c.Allocation,
+		StartAt:          c.StartAt,
+		EndAt:            c.EndAt,
+		Metadata:         c.Metadata,
+		KeyPrefix:        c.KeyPrefix,
+		Processor:        c.Processor,
+		Running:          c.running,
+		Paused:           c.paused,
+		State:            c.State(),
+	}
+}
+
+// newActivityFromRecord creates an activity from the record. The settings absent in the record are filled with default values.
+func newActivityFromRecord(record *ActivityRecord) *Activity {
+	activity := &Activity{
+		ID:               record.ID,
+		RedisServerIndex: record.RedisServerIndex,
+		Batch:            record.Batch,
+		Interval:         record.Interval,
+		Mode:             record.Mode,
+		AdaptiveBatch:    record.AdaptiveBatch,
+		RestartPolicy:    record.RestartPolicy,
+		Retry:            record.Retry,
+		Partitions:       record.Partitions,
+		Capacity:         record.Capacity,
+		Weight:           record.Weight,
+		DryRun:           record.DryRun,
+		Waitlist:         record.Waitlist,
+		Quota:            record.Quota,
+		Allocation:       record.Allocation,
+		StartAt:          record.StartAt,
+		EndAt:            record.EndAt,
+		Metadata:         record.Metadata,
+		KeyPrefix:        record.KeyPrefix,
+		Processor:        record.Processor,
+		state:            restoredStateOf(record.State),
+	}
+	activity.fillDefaultSettings()
+	return activity
+}
+
+// attach specifies the pool that the activity belongs to. nil means that the activity is detached and will not be persisted.
+func (c *Activity) attach(pool *ActivityPool) {
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	c.pool = pool
+}
+
+// persist builds the record of the current definition and the desired run state, which is written by flush().
+// The caller must hold contextCancelFuncRWLock, and call flush() after releasing it,
+// so that the readers of the activity are not blocked by the registry.
+func (c *Activity) persist() {
+	c.persisting = c.record()
+}
+
+// flush writes the record built by the last persist() to the registry of the pool it belongs to, if any.
+// The records are written in the order they are built, and the stale ones are skipped.
+// Failures are only logged, because the registry must not block the worker.
+func (c *Activity) flush() {
+	c.flushLock.Lock()
+	defer c.flushLock.Unlock()
+	c.contextCancelFuncRWLock.Lock()
+	record, pool := c.persisting, c.pool
+	c.persisting = nil
+	c.contextCancelFuncRWLock.Unlock()
+	if record == nil {
+		return
+	}
+	if err := pool.save(record); err != nil {
+		log.Printf("[ActivityID: %d] failed to persist: %s\n", c.ID, err.Error())
+	}
+}
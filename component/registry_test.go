@@ -0,0 +1,151 @@
+package component
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryActivityRegistry is an ActivityRegistry that keeps records in memory, used for testing.
+type memoryActivityRegistry struct {
+	records map[uint64]ActivityRecord
+	lock    sync.Mutex
+}
+
+func newMemoryActivityRegistry() *memoryActivityRegistry {
+	return &memoryActivityRegistry{records: make(map[uint64]ActivityRecord)}
+}
+
+func (r *memoryActivityRegistry) Save(ctx context.Context, record *ActivityRecord) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.records[record.ID] = *record
+	return nil
+}
+
+func (r *memoryActivityRegistry) Delete(ctx context.Context, id uint64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.records, id)
+	return nil
+}
+
+func (r *memoryActivityRegistry) Load(ctx context.Context) ([]ActivityRecord, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	records := make([]ActivityRecord, 0, len(r.records))
+	for _, v := range r.records {
+		records = append(records, v)
+	}
+	return records, nil
+}
+
+func (r *memoryActivityRegistry) get(id uint64) (ActivityRecord, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	record, existed := r.records[id]
+	return record, existed
+}
+
+// blockingActivityRegistry is a memoryActivityRegistry whose Save blocks until released, used for testing.
+type blockingActivityRegistry struct {
+	*memoryActivityRegistry
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingActivityRegistry) Save(ctx context.Context, record *ActivityRecord) error {
+	r.saving <- struct{}{}
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.memoryActivityRegistry.Save(ctx, record)
+}
+
+func TestActivityPool_Registry(t *testing.T) {
+	registry := newMemoryActivityRegistry()
+	Activities = InitActivityPool()
+	Activities.SetRegistry(registry)
+	defer teardownWorker(t)
+
+	index := uint8(1)
+	assert.Nil(t, Activities.New(1, &index))
+	assert.Nil(t, Activities.New(2, nil))
+	record, existed := registry.get(1)
+	assert.True(t, existed, "The newly added activity should be saved.")
+	assert.Equal(t, uint8(1), record.RedisServerIndex)
+	assert.False(t, record.Running)
+
+	activity, err := Activities.GetActivity(2)
+	assert.Nil(t, err)
+	assert.Nil(t, activity.Start(context.Background()))
+	record, _ = registry.get(2)
+	assert.True(t, record.Running, "The started activity should be persisted as running.")
+
+	// Stopping all workers when exiting should not change the desired run state.
+	assert.Equal(t, 1, Activities.StopAll())
+	record, _ = registry.get(2)
+	assert.True(t, record.Running)
+
+	assert.Nil(t, Activities.Remove(1, true))
+	_, existed = registry.get(1)
+	assert.False(t, existed, "The removed activity should be deleted.")
+
+	// Rebuild the pool from the registry, the activity that was running should be restarted.
+	Activities = InitActivityPool()
+	Activities.SetRegistry(registry)
+	count, err := Activities.Restore(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	activity, err = Activities.GetActivity(2)
+	assert.Nil(t, err)
+	assert.True(t, activity.IsWorking())
+
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+	record, _ = registry.get(2)
+	assert.False(t, record.Running, "The stopped activity should be persisted as not running.")
+}
+
+func TestActivity_PersistOutsideLock(t *testing.T) {
+	registry := &blockingActivityRegistry{
+		memoryActivityRegistry: newMemoryActivityRegistry(),
+		saving:                 make(chan struct{}, 1),
+		release:                make(chan struct{}),
+	}
+	Activities = InitActivityPool()
+	defer teardownWorker(t)
+	assert.Nil(t, Activities.New(1, nil))
+	Activities.SetRegistry(registry)
+	activity, _ := Activities.GetActivity(1)
+
+	started := make(chan error)
+	go func() {
+		started <- activity.Start(context.Background())
+	}()
+	<-registry.saving
+	locked := make(chan struct{})
+	go func() {
+		activity.contextCancelFuncRWLock.RLock()
+		defer activity.contextCancelFuncRWLock.RUnlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("The activity should not be locked while saving the record.")
+	}
+	close(registry.release)
+	assert.Nil(t, <-started)
+	record, _ := registry.get(1)
+	assert.True(t, record.Running, "The record should be saved before returning.")
+
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+	<-registry.saving
+	record, _ = registry.get(1)
+	assert.False(t, record.Running)
+}
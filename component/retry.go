@@ -0,0 +1,96 @@
+package component
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrActivityRetryInvalid = errors.New("the retry must have a delay greater than 0 if the attempts are greater than 0, and is only supported by the function processor")
+
+// ActivityRetry specifies how the applications whose applicant is missing are retried.
+//
+// Such an application is put into the retry key of the partition, a sorted set scored by when it is retried,
+// instead of the dead-letter list. The worker retries the due applications before popping new ones in each batch,
+// and the application whose applicant is still missing after Attempts retries is moved into the dead-letter list.
+// The time when the application was popped at first is kept, and used as the score of the seat,
+// so that the applicant written a moment later than the application keeps the place in the queue.
+// Retrying is only supported by the function processor, see ProcessorFunction, and not in dry-run mode, see WithDryRun().
+// A zero Attempts means that the applications are not retried.
+type ActivityRetry struct {
+	Attempts uint16 `json:"attempts" yaml:"Attempts"` // The maximum number of retries of each application.
+	Delay    uint32 `json:"delay" yaml:"Delay"`       // The delay before each retry, in milliseconds.
+}
+
+// Enabled determines whether the applications are retried.
+func (r *ActivityRetry) Enabled() bool {
+	return r != nil && r.Attempts > 0
+}
+
+// Validate checks the delay if enabled.
+func (r *ActivityRetry) Validate() error {
+	if !r.Enabled() {
+		return nil
+	}
+	if r.Delay == 0 {
+		return ErrActivityRetryInvalid
+	}
+	return nil
+}
+
+// WithRetry specifies how the applications whose applicant is missing are retried.
+// If not specified, EnvActivity.Retry is used. A zero Attempts means no retry regardless of the environment.
+func WithRetry(retry *ActivityRetry) ActivityOption {
+	return func(activity *Activity) {
+		activity.Retry = nil
+		if retry != nil {
+			copied := *retry
+			activity.Retry = &copied
+		}
+	}
+}
+
+// validateRetry checks the retry against the processor.
+func (c *Activity) validateRetry(retry *ActivityRetry) error {
+	if err := retry.Validate(); err != nil {
+		return err
+	}
+	if retry.Enabled() && c.Processor != ProcessorFunction {
+		return ErrActivityRetryInvalid
+	}
+	return nil
+}
+
+// defaultActivityRetry returns a copy of the retry configured, nil if not configured.
+func defaultActivityRetry() *ActivityRetry {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Retry.Enabled() {
+		retry := *GlobalEnv.Activity.Retry
+		return &retry
+	}
+	return nil
+}
+
+// getRetry returns a copy of the retry, nil if not specified.
+func (c *Activity) getRetry() *ActivityRetry {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	if c.Retry == nil {
+		return nil
+	}
+	retry := *c.Retry
+	return &retry
+}
+
+func (c *Activity) GetRedisServerRetryKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Retry, c.ID)
+}
+
+// GetRedisServerPartitionRetryKeyName returns the retry key of the partition,
+// which is located in the redis server of the partition.
+// If the activity is not partitioned, it is the same as GetRedisServerRetryKeyName().
+func (c *Activity) GetRedisServerPartitionRetryKeyName(partition int) string {
+	if len(c.Partitions) == 0 {
+		return c.GetRedisServerRetryKeyName()
+	}
+	return c.GetRedisServerRetryKeyName() + ":" + strconv.Itoa(partition)
+}
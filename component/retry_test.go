@@ -0,0 +1,48 @@
+package component
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityRetry_Validate(t *testing.T) {
+	var retry *ActivityRetry
+	assert.False(t, retry.Enabled())
+	assert.Nil(t, retry.Validate())
+	assert.Nil(t, (&ActivityRetry{}).Validate(), "The delay should not be checked if disabled.")
+	assert.ErrorIs(t, (&ActivityRetry{Attempts: 3}).Validate(), ErrActivityRetryInvalid)
+	assert.Nil(t, (&ActivityRetry{Attempts: 3, Delay: 500}).Validate())
+}
+
+func TestActivity_Retry(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.ErrorIs(t, Activities.New(1, nil, WithRetry(&ActivityRetry{Attempts: 1})), ErrActivityRetryInvalid)
+	assert.Nil(t, Activities.New(1, nil, WithPartitions(NewActivityPartitions([]uint8{0, 0}))))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Status().Retry)
+	task := activity.newBatchTask(1)
+	assert.Empty(t, task.RetryKey, "The applications should not be retried by default.")
+
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{Attempts: 2}}), ErrActivityRetryInvalid)
+	assert.Nil(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{Attempts: 2, Delay: 500}}))
+	assert.Equal(t, &ActivityRetry{Attempts: 2, Delay: 500}, activity.Status().Retry)
+	assert.Equal(t, &ActivityRetry{Attempts: 2, Delay: 500}, activity.record().Retry)
+	task = activity.newBatchTask(1)
+	assert.Equal(t, "activity_retry_1:1", task.RetryKey)
+	assert.Equal(t, ActivityRetry{Attempts: 2, Delay: 500}, task.Retry)
+
+	assert.Nil(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{}}))
+	assert.Empty(t, activity.newBatchTask(1).RetryKey, "Zero attempts should disable retrying.")
+
+	assert.ErrorIs(t, Activities.New(2, nil, WithProcessor(ProcessorTransaction), WithRetry(&ActivityRetry{Attempts: 1, Delay: 500})), ErrActivityRetryInvalid)
+	assert.Nil(t, Activities.New(2, nil, WithProcessor(ProcessorNaive), WithRetry(&ActivityRetry{})))
+	activity, _ = Activities.GetActivity(2)
+	assert.ErrorIs(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{Attempts: 2, Delay: 500}}), ErrActivityRetryInvalid, "Only the function processor retries the applications.")
+	assert.Nil(t, activity.Update(&ActivitySettings{Retry: &ActivityRetry{}}))
+
+	assert.ErrorIs(t, (&ActivityTemplate{Name: "retry", Processor: ProcessorTransaction, Retry: &ActivityRetry{Attempts: 1, Delay: 500}}).Validate(), ErrActivityRetryInvalid)
+	assert.Nil(t, (&ActivityTemplate{Name: "retry", Retry: &ActivityRetry{Attempts: 1, Delay: 500}}).Validate())
+}
@@ -0,0 +1,117 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+var ErrActivityScheduleInvalid = errors.New("the end of the schedule must be after the start")
+
+// ActivityScheduleAction represents what the scheduler will do to the activity.
+type ActivityScheduleAction string
+
+const (
+	ActivityScheduleActionStart ActivityScheduleAction = "start"
+	ActivityScheduleActionStop  ActivityScheduleAction = "stop"
+)
+
+// ActivityScheduleEntry represents an upcoming action of the scheduler.
+type ActivityScheduleEntry struct {
+	ActivityID uint64                 `json:"activity_id"`
+	Action     ActivityScheduleAction `json:"action"`
+	At         time.Time              `json:"at"`
+}
+
+// WithSchedule specifies when the worker is started and stopped automatically. Either of them can be nil.
+func WithSchedule(startAt *time.Time, endAt *time.Time) ActivityOption {
+	return func(activity *Activity) {
+		activity.StartAt = startAt
+		activity.EndAt = endAt
+	}
+}
+
+// validateSchedule checks that the end of the schedule is after the start, if both are specified.
+func (c *Activity) validateSchedule() error {
+	if c.StartAt != nil && c.EndAt != nil && !c.EndAt.After(*c.StartAt) {
+		return ErrActivityScheduleInvalid
+	}
+	return nil
+}
+
+// schedule starts or stops the worker of the activity according to its schedule.
+//
+// The worker is started once the start time is reached, only if the activity has never been started,
+// so that the activity stopped by the operator during the window will not be restarted.
+// The worker is stopped with ErrActivityFinished once the end time is reached,
+// and the pending restart, if any, is cancelled, so that the worker will not be restarted after the window.
+func (c *Activity) schedule(now time.Time) {
+	if c.EndAt != nil && !now.Before(*c.EndAt) {
+		if c.IsWorking() || c.isRestartPending() {
+			if err := c.Stop(ErrActivityFinished); err != nil {
+				log.Printf("[ActivityID: %d] failed to stop the worker on schedule: %s\n", c.ID, err.Error())
+			}
+		}
+		return
+	}
+	if c.StartAt != nil && !now.Before(*c.StartAt) && c.State() == ActivityStateCreated {
+		if err := c.Start(context.Background()); err != nil {
+			log.Printf("[ActivityID: %d] failed to start the worker on schedule: %s\n", c.ID, err.Error())
+		}
+	}
+}
+
+// Schedule returns the upcoming actions of the scheduler after now, the earliest comes first.
+func (a *ActivityPool) Schedule(now time.Time) []ActivityScheduleEntry {
+	a.ActivitiesRWLock.RLock()
+	defer a.ActivitiesRWLock.RUnlock()
+	entries := make([]ActivityScheduleEntry, 0)
+	for _, v := range a.Activities {
+		if v.StartAt != nil && v.StartAt.After(now) && v.State() == ActivityStateCreated {
+			entries = append(entries, ActivityScheduleEntry{ActivityID: v.ID, Action: ActivityScheduleActionStart, At: *v.StartAt})
+		}
+		if v.EndAt != nil && v.EndAt.After(now) {
+			entries = append(entries, ActivityScheduleEntry{ActivityID: v.ID, Action: ActivityScheduleActionStop, At: *v.EndAt})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].At.Equal(entries[j].At) {
+			return entries[i].ActivityID < entries[j].ActivityID
+		}
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries
+}
+
+// tick applies the schedule of all activities at the specified time.
+func (a *ActivityPool) tick(now time.Time) {
+	a.ActivitiesRWLock.RLock()
+	activities := make([]*Activity, 0, len(a.Activities))
+	for _, v := range a.Activities {
+		if v.StartAt != nil || v.EndAt != nil {
+			activities = append(activities, v)
+		}
+	}
+	a.ActivitiesRWLock.RUnlock()
+	for _, v := range activities {
+		v.schedule(now)
+	}
+}
+
+// RunScheduler checks the schedule of all activities every interval until the context is done.
+// Since the schedule is persisted along with the activity, it will continue after restoring.
+func (a *ActivityPool) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	a.tick(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.tick(now)
+		}
+	}
+}
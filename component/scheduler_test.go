@@ -0,0 +1,58 @@
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityPool_Schedule(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	now := time.Now()
+	startAt := now.Add(time.Hour)
+	endAt := now.Add(2 * time.Hour)
+	assert.ErrorIs(t, Activities.New(1, nil, WithSchedule(&endAt, &startAt)), ErrActivityScheduleInvalid)
+	assert.Nil(t, Activities.New(1, nil, WithSchedule(&startAt, &endAt)))
+	assert.Nil(t, Activities.New(2, nil, WithSchedule(nil, &startAt)))
+	assert.Nil(t, Activities.New(3, nil))
+
+	entries := Activities.Schedule(now)
+	assert.Equal(t, []ActivityScheduleEntry{
+		{ActivityID: 1, Action: ActivityScheduleActionStart, At: startAt},
+		{ActivityID: 2, Action: ActivityScheduleActionStop, At: startAt},
+		{ActivityID: 1, Action: ActivityScheduleActionStop, At: endAt},
+	}, entries)
+
+	activity, _ := Activities.GetActivity(1)
+
+	// Nothing happens before the start.
+	Activities.tick(now)
+	assert.Equal(t, ActivityStateCreated, activity.State())
+
+	// The worker is started once the start time is reached.
+	Activities.tick(startAt)
+	assert.Equal(t, ActivityStateRunning, activity.State())
+	assert.Len(t, Activities.Schedule(startAt), 1, "Only the stop action is upcoming.")
+
+	// The worker is stopped once the end time is reached, and the activity will be finished.
+	Activities.tick(endAt)
+	assert.Equal(t, ActivityStateDraining, activity.State())
+	transitions := activity.StateTransitions()
+	assert.Equal(t, ErrActivityFinished.Error(), transitions[len(transitions)-1].Cause)
+	activity.settle(ErrActivityFinished)
+	assert.Equal(t, ActivityStateFinished, activity.State())
+
+	// The finished activity will not be started again.
+	Activities.tick(startAt)
+	assert.Equal(t, ActivityStateFinished, activity.State())
+}
+
+func TestRestoredStateOf(t *testing.T) {
+	assert.Equal(t, ActivityStateCreated, restoredStateOf(""))
+	assert.Equal(t, ActivityStateStopped, restoredStateOf(ActivityStateRunning))
+	assert.Equal(t, ActivityStateStopped, restoredStateOf(ActivityStateDraining))
+	assert.Equal(t, ActivityStateFinished, restoredStateOf(ActivityStateFinished))
+}
@@ -0,0 +1,157 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrApplicantNotSeated = errors.New("the applicant does not have a seat")
+
+// maxActivitySeatEvents is the number of recent seat events kept for each activity.
+const maxActivitySeatEvents = 32
+
+// ActivitySeatEventType is the kind of change to a seat.
+type ActivitySeatEventType string
+
+const (
+	ActivitySeatEventRevoked  ActivitySeatEventType = "revoked"  // The seat of the applicant was revoked.
+	ActivitySeatEventPromoted ActivitySeatEventType = "promoted" // The applicant was promoted from the waitlist into a seat.
+)
+
+// ActivitySeatEvent represents a change to a seat outside the batches.
+type ActivitySeatEvent struct {
+	Type       ActivitySeatEventType `json:"type"`
+	ActivityID uint64                `json:"activity_id"`
+	Applicant  string                `json:"applicant"`
+	Reason     string                `json:"reason"`             // Why the seat was revoked, or the seat was vacated for the promotion.
+	Replaced   string                `json:"replaced,omitempty"` // The applicant whose seat was revoked, only for the promotion.
+	Time       time.Time             `json:"time"`
+}
+
+// ActivitySeatEventHandler handles the seat events. It is called synchronously, so it should return quickly.
+type ActivitySeatEventHandler func(event ActivitySeatEvent)
+
+var seatEventHandlers = map[string]ActivitySeatEventHandler{}
+var seatEventHandlersRWLock sync.RWMutex
+
+// RegisterSeatEventHandler registers the handler of the seat events of all activities with the name,
+// replacing the existing one with the same name.
+func RegisterSeatEventHandler(name string, handler ActivitySeatEventHandler) {
+	seatEventHandlersRWLock.Lock()
+	defer seatEventHandlersRWLock.Unlock()
+	seatEventHandlers[name] = handler
+}
+
+// UnregisterSeatEventHandler removes the handler registered with the name.
+func UnregisterSeatEventHandler(name string) {
+	seatEventHandlersRWLock.Lock()
+	defer seatEventHandlersRWLock.Unlock()
+	delete(seatEventHandlers, name)
+}
+
+// activitySeatEvents keeps the recent seat events of an activity.
+type activitySeatEvents struct {
+	lock  sync.RWMutex
+	items []ActivitySeatEvent
+}
+
+func (c *Activity) GetRedisServerRevocationKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Revocation, c.ID)
+}
+
+// emitSeatEvent appends the event to the recent seat events, and passes it to the handlers ordered by name.
+func (c *Activity) emitSeatEvent(event ActivitySeatEvent) {
+	log.Printf("[ActivityID: %d]: seat of %s %s: %s.\n", c.ID, event.Applicant, event.Type, event.Reason)
+	c.seatEvents.lock.Lock()
+	c.seatEvents.items = append(c.seatEvents.items, event)
+	if len(c.seatEvents.items) > maxActivitySeatEvents {
+		c.seatEvents.items = c.seatEvents.items[len(c.seatEvents.items)-maxActivitySeatEvents:]
+	}
+	c.seatEvents.lock.Unlock()
+
+	seatEventHandlersRWLock.RLock()
+	names := make([]string, 0, len(seatEventHandlers))
+	for name := range seatEventHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	handlers := make([]ActivitySeatEventHandler, len(names))
+	for i, name := range names {
+		handlers[i] = seatEventHandlers[name]
+	}
+	seatEventHandlersRWLock.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// SeatEvents returns the recent seat events of the activity, the earliest comes first.
+func (c *Activity) SeatEvents() []ActivitySeatEvent {
+	c.seatEvents.lock.RLock()
+	defer c.seatEvents.lock.RUnlock()
+	events := make([]ActivitySeatEvent, len(c.seatEvents.items))
+	copy(events, c.seatEvents.items)
+	return events
+}
+
+// RevokeSeat revokes the latest seat of the applicant for the reason, by the redis function "revoke_seat",
+// which records the reason in the revocation key, and promotes the earliest applicant in the waitlist into the vacant seat
+// if the waitlist is enabled, see WithWaitlist(). The promoted applicant keeps the time when the application was popped.
+// The events of the revocation and the promotion are returned and emitted, see RegisterSeatEventHandler().
+// If the applicant does not have a seat, an ErrApplicantNotSeated error will be returned.
+func (c *Activity) RevokeSeat(ctx context.Context, applicant string, reason string) ([]ActivitySeatEvent, error) {
+	// The waitlist key is passed as an empty string if disabled.
+	// The seat count key is always passed, since the applicant may have got more than one seat before the quota was lowered.
+	waitlistKey := ""
+	if c.IsWaitlistEnabled() {
+		waitlistKey = c.GetRedisServerWaitlistKeyName()
+	}
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	val, err := client.FCall(ctx, "revoke_seat", []string{
+		c.GetRedisServerSeatKeyName(),
+		c.GetRedisServerRevocationKeyName(),
+		waitlistKey,
+		c.GetRedisServerSeatCountKeyName(),
+	}, applicant, reason, c.Capacity, c.GetQuota()).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return c.recordRevocation(applicant, reason, val)
+}
+
+// recordRevocation parses the reply of the redis function, and emits the events.
+func (c *Activity) recordRevocation(applicant string, reason string, val []interface{}) ([]ActivitySeatEvent, error) {
+	if revoked, _ := val[0].(int64); revoked == 0 {
+		return nil, ErrApplicantNotSeated
+	}
+	now := time.Now()
+	events := []ActivitySeatEvent{{
+		Type:       ActivitySeatEventRevoked,
+		ActivityID: c.ID,
+		Applicant:  applicant,
+		Reason:     reason,
+		Time:       now,
+	}}
+	if len(val) > 1 {
+		promoted, _ := val[1].(string)
+		events = append(events, ActivitySeatEvent{
+			Type:       ActivitySeatEventPromoted,
+			ActivityID: c.ID,
+			Applicant:  promoted,
+			Reason:     reason,
+			Replaced:   applicant,
+			Time:       now,
+		})
+	}
+	for _, event := range events {
+		c.emitSeatEvent(event)
+	}
+	return events, nil
+}
@@ -0,0 +1,48 @@
+package component
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_RecordRevocation(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil, WithCapacity(2), WithWaitlist(true)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, "activity_revocation_1", activity.GetRedisServerRevocationKeyName())
+	assert.Contains(t, activity.GetRedisServerDataKeyNames(), activity.GetRedisServerRevocationKeyName())
+
+	var received []ActivitySeatEvent
+	RegisterSeatEventHandler("test", func(event ActivitySeatEvent) {
+		received = append(received, event)
+	})
+	defer UnregisterSeatEventHandler("test")
+
+	events, err := activity.recordRevocation("u9", "fraud", []interface{}{int64(0)})
+	assert.ErrorIs(t, err, ErrApplicantNotSeated)
+	assert.Nil(t, events)
+
+	events, err = activity.recordRevocation("u1", "payment failed", []interface{}{int64(1), "u3"})
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, ActivitySeatEventRevoked, events[0].Type)
+	assert.Equal(t, "u1", events[0].Applicant)
+	assert.Equal(t, ActivitySeatEventPromoted, events[1].Type)
+	assert.Equal(t, "u3", events[1].Applicant)
+	assert.Equal(t, "u1", events[1].Replaced)
+	assert.Equal(t, "payment failed", events[1].Reason)
+
+	events, err = activity.recordRevocation("u2", "fraud", []interface{}{int64(1)})
+	assert.Nil(t, err)
+	assert.Len(t, events, 1, "Nobody should be promoted if the waitlist is empty.")
+	assert.Equal(t, received, activity.SeatEvents())
+	assert.Len(t, received, 3)
+
+	for i := 0; i < maxActivitySeatEvents; i++ {
+		activity.recordRevocation("u1", "fraud", []interface{}{int64(1)})
+	}
+	assert.Len(t, activity.SeatEvents(), maxActivitySeatEvents)
+}
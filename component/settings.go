@@ -0,0 +1,255 @@
+package component
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrActivityBatchInvalid = errors.New("the batch must be greater than 0")
+var ErrActivityIntervalInvalid = errors.New("the interval must be greater than 0")
+var ErrActivityModeInvalid = errors.New("the mode must be poll or block")
+
+// ActivityMode represents how the worker waits for applications.
+type ActivityMode string
+
+const (
+	// ActivityModePoll means that the worker processes a batch after each interval, whether there are applications or not.
+	ActivityModePoll ActivityMode = "poll"
+	// ActivityModeBlock means that the worker blocks until the application queue has data,
+	// and then processes batches back to back while each batch is full.
+	// The interval is the longest time to block, and the time to wait between two batches if failed to block.
+	ActivityModeBlock ActivityMode = "block"
+)
+
+// Validate checks whether the mode is known.
+func (m ActivityMode) Validate() error {
+	if m != ActivityModePoll && m != ActivityModeBlock {
+		return ErrActivityModeInvalid
+	}
+	return nil
+}
+
+// ActivitySettings represents the settings that can be changed while the worker is working.
+// The nil field will not be changed.
+type ActivitySettings struct {
+	Interval *uint16 // The interval between two batches, in milliseconds.
+	Batch    *uint16 // The number of applications processed in each batch.
+	Mode     *ActivityMode
+	// AdaptiveBatch specifies how the batch is adjusted, a zero budget means that the batch is fixed.
+	AdaptiveBatch *ActivityAdaptiveBatch
+	// RestartPolicy specifies whether and when the worker is restarted after it exits unexpectedly.
+	RestartPolicy *ActivityRestartPolicy
+	// Retry specifies how the applications whose applicant is missing are retried, zero attempts mean no retry.
+	Retry *ActivityRetry
+	// Weight specifies the share of the activity when competing with others for a limited redis server.
+	Weight *uint16
+	// DryRun specifies whether to simulate the batches without writing anything, see WithDryRun().
+	DryRun *bool
+	// Waitlist specifies whether to keep the applicants over capacity in the waitlist, see WithWaitlist().
+	Waitlist *bool
+	// Quota specifies the maximum number of seats of each applicant, see WithQuota().
+	Quota *uint16
+}
+
+// WithBatch specifies the number of applications processed in each batch.
+// If not specified, EnvActivity.Batch is used.
+func WithBatch(batch uint16) ActivityOption {
+	return func(activity *Activity) {
+		activity.Batch = batch
+	}
+}
+
+// WithInterval specifies the interval between two batches, in milliseconds.
+// If not specified, EnvActivity.Interval is used.
+func WithInterval(interval uint16) ActivityOption {
+	return func(activity *Activity) {
+		activity.Interval = interval
+	}
+}
+
+// WithMode specifies how the worker waits for applications.
+// If not specified, EnvActivity.Mode is used.
+func WithMode(mode ActivityMode) ActivityOption {
+	return func(activity *Activity) {
+		activity.Mode = mode
+	}
+}
+
+// defaultActivityBatch returns the batch configured, or the default value if the environment has not been loaded.
+func defaultActivityBatch() uint16 {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Batch != nil {
+		return *GlobalEnv.Activity.Batch
+	}
+	return *(&EnvActivity{}).GetBatchDefault()
+}
+
+// defaultActivityInterval returns the interval configured, or the default value if the environment has not been loaded.
+func defaultActivityInterval() uint16 {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Interval != nil {
+		return *GlobalEnv.Activity.Interval
+	}
+	return *(&EnvActivity{}).GetIntervalDefault()
+}
+
+// defaultActivityMode returns the mode configured, or the default value if the environment has not been loaded.
+func defaultActivityMode() ActivityMode {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.Mode != nil {
+		return *GlobalEnv.Activity.Mode
+	}
+	return *(&EnvActivity{}).GetModeDefault()
+}
+
+// fillDefaultSettings fills the default values for the unspecified settings.
+func (c *Activity) fillDefaultSettings() {
+	if c.Batch == 0 {
+		c.Batch = defaultActivityBatch()
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultActivityInterval()
+	}
+	if len(c.Mode) == 0 {
+		c.Mode = defaultActivityMode()
+	}
+	if c.AdaptiveBatch == nil {
+		c.AdaptiveBatch = defaultActivityAdaptiveBatch()
+	}
+	if c.RestartPolicy == nil {
+		c.RestartPolicy = defaultActivityRestartPolicy()
+	}
+	if c.Retry == nil {
+		c.Retry = defaultActivityRetry()
+	}
+	if c.Weight == 0 {
+		c.Weight = defaultActivityWeight()
+	}
+	if c.Quota == 0 {
+		c.Quota = defaultActivityQuota()
+	}
+	c.resetEffectiveBatch()
+	if len(c.Processor) == 0 {
+		c.Processor = defaultActivityProcessor()
+	}
+	if len(c.Allocation) == 0 {
+		c.Allocation = ActivityAllocationFIFO
+	}
+}
+
+// GetBatch returns the number of applications processed in each batch.
+func (c *Activity) GetBatch() uint16 {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.Batch
+}
+
+// GetInterval returns the interval between two batches.
+func (c *Activity) GetInterval() time.Duration {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return time.Duration(c.Interval) * time.Millisecond
+}
+
+// GetMode returns how the worker waits for applications.
+func (c *Activity) GetMode() ActivityMode {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.Mode
+}
+
+// Update changes the settings of the activity. The worker takes the new settings on the next tick.
+//
+// If the batch is 0, an ErrActivityBatchInvalid error will be returned.
+// If the interval is 0, an ErrActivityIntervalInvalid error will be returned.
+// If the mode is unknown, an ErrActivityModeInvalid error will be returned.
+// If the adaptive batch is invalid, an ErrActivityAdaptiveBatchInvalid error will be returned.
+// If the restart policy is invalid, an ErrActivityRestartPolicyInvalid error will be returned.
+// If the retry is invalid, an ErrActivityRetryInvalid error will be returned.
+// If the weight is 0, an ErrActivityWeightInvalid error will be returned.
+// If the quota is 0, or greater than 1 while any partition is remote, an ErrActivityQuotaInvalid error will be returned.
+// If dry-run mode would be enabled together with the waitlist, the retries, a quota greater than 1 or the lottery,
+// an ErrActivityDryRunUnsupported error will be returned.
+// Changing the batch or the adaptive batch restarts adjusting from the batch.
+// Switching dry-run mode discards the state of the simulation.
+// Nothing is changed if any error is returned.
+func (c *Activity) Update(settings *ActivitySettings) error {
+	if settings == nil {
+		return nil
+	}
+	if settings.Batch != nil && *settings.Batch == 0 {
+		return ErrActivityBatchInvalid
+	}
+	if settings.Interval != nil && *settings.Interval == 0 {
+		return ErrActivityIntervalInvalid
+	}
+	if settings.Mode != nil {
+		if err := settings.Mode.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := settings.AdaptiveBatch.Validate(); err != nil {
+		return err
+	}
+	if err := settings.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := c.validateRetry(settings.Retry); err != nil {
+		return err
+	}
+	if settings.Weight != nil && *settings.Weight == 0 {
+		return ErrActivityWeightInvalid
+	}
+	if settings.Quota != nil {
+		if err := c.validateQuota(*settings.Quota); err != nil {
+			return err
+		}
+	}
+	defer c.flush()
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	if err := c.validateDryRun(settings); err != nil {
+		return err
+	}
+	c.settingsRWLock.Lock()
+	if settings.Batch != nil {
+		c.Batch = *settings.Batch
+	}
+	if settings.Interval != nil {
+		c.Interval = *settings.Interval
+	}
+	if settings.Mode != nil {
+		c.Mode = *settings.Mode
+	}
+	if settings.AdaptiveBatch != nil {
+		adaptive := *settings.AdaptiveBatch
+		c.AdaptiveBatch = &adaptive
+	}
+	if settings.RestartPolicy != nil {
+		policy := *settings.RestartPolicy
+		c.RestartPolicy = &policy
+	}
+	if settings.Retry != nil {
+		retry := *settings.Retry
+		c.Retry = &retry
+	}
+	if settings.Weight != nil {
+		c.Weight = *settings.Weight
+	}
+	dryRunSwitched := settings.DryRun != nil && *settings.DryRun != c.DryRun
+	if settings.DryRun != nil {
+		c.DryRun = *settings.DryRun
+	}
+	if settings.Waitlist != nil {
+		c.Waitlist = *settings.Waitlist
+	}
+	if settings.Quota != nil {
+		c.Quota = *settings.Quota
+	}
+	if settings.Batch != nil || settings.AdaptiveBatch != nil {
+		c.resetEffectiveBatch()
+	}
+	c.settingsRWLock.Unlock()
+	if dryRunSwitched {
+		c.resetDryRun()
+	}
+	c.persist()
+	return nil
+}
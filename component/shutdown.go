@@ -0,0 +1,58 @@
+package component
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ActivityShutdownResult reports how the worker of an activity was shut down.
+type ActivityShutdownResult struct {
+	ID      uint64        `json:"id"`
+	State   ActivityState `json:"state"`   // The state after shutting down.
+	Stopped bool          `json:"stopped"` // Whether the worker was working or waiting to be restarted, and has been stopped.
+	Drained bool          `json:"drained"` // Whether the worker has finished its current batch and exited before the deadline.
+	Elapsed time.Duration `json:"elapsed"` // How long it took the worker to exit.
+}
+
+// Shutdown stops the workers of all activities with ErrAllWorkersStopped, so that the desired run state is kept,
+// and waits for them to finish their current batches until the context is done.
+// The pending restarts are cancelled as well.
+// Returns the result of each activity, ordered by activity ID.
+func (a *ActivityPool) Shutdown(ctx context.Context) []ActivityShutdownResult {
+	if a == nil {
+		return nil
+	}
+	a.ActivitiesRWLock.RLock()
+	activities := make([]*Activity, 0, len(a.Activities))
+	for _, v := range a.Activities {
+		activities = append(activities, v)
+	}
+	a.ActivitiesRWLock.RUnlock()
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].ID < activities[j].ID
+	})
+
+	tmStart := time.Now()
+	results := make([]ActivityShutdownResult, len(activities))
+	exited := make([]<-chan struct{}, len(activities))
+	for i, v := range activities {
+		results[i].ID = v.ID
+		exited[i] = v.exitedChan()
+		results[i].Stopped = v.Stop(ErrAllWorkersStopped) == nil
+	}
+	for i, v := range activities {
+		if exited[i] == nil {
+			results[i].Drained = true
+		} else {
+			select {
+			case <-exited[i]:
+				results[i].Drained = true
+			case <-ctx.Done():
+			}
+		}
+		results[i].Elapsed = time.Since(tmStart)
+		results[i].State = v.State()
+	}
+	return results
+}
@@ -0,0 +1,121 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestActivityPool_Shutdown 测试退出时等待工作协程完成当前批次，并保留期望的运行状态。
+func TestActivityPool_Shutdown(t *testing.T) {
+	registry := newMemoryActivityRegistry()
+	setupWorker(t)
+	Activities.SetRegistry(registry)
+	defer teardownWorker(t)
+
+	completed := make(chan uint64, 2)
+	RegisterProcessor("slow", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		time.Sleep(time.Duration(task.Activity.ID) * 50 * time.Millisecond)
+		completed <- task.Activity.ID
+		return &BatchResult{}, nil
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "slow")
+		processorsRWLock.Unlock()
+	}()
+
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("slow"), WithInterval(1)))
+	assert.Nil(t, Activities.New(4, nil, WithProcessor("slow"), WithInterval(1)))
+	assert.Nil(t, Activities.New(7, nil))
+	for _, id := range []uint64{1, 4} {
+		activity, _ := Activities.GetActivity(id)
+		assert.Nil(t, activity.Start(context.Background()))
+	}
+	time.Sleep(10 * time.Millisecond) // Both are in the middle of their first batches.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results := Activities.Shutdown(ctx)
+	assert.Len(t, results, 3)
+	assert.Equal(t, []uint64{1, 4, 7}, []uint64{results[0].ID, results[1].ID, results[2].ID})
+
+	assert.True(t, results[0].Stopped)
+	assert.True(t, results[0].Drained, "The worker should finish its current batch before the deadline.")
+	assert.Equal(t, ActivityStateStopped, results[0].State)
+	assert.Equal(t, uint64(1), <-completed, "The current batch should not be cut off.")
+
+	assert.True(t, results[1].Stopped)
+	assert.False(t, results[1].Drained, "The worker whose batch exceeds the deadline should be reported.")
+	assert.Equal(t, ActivityStateDraining, results[1].State)
+
+	assert.False(t, results[2].Stopped, "The activity never started should not be stopped.")
+	assert.True(t, results[2].Drained)
+
+	record, _ := registry.get(1)
+	assert.True(t, record.Running, "The desired run state should be kept for restoring.")
+	assert.Equal(t, uint64(4), <-completed)
+}
+
+// TestActivityPool_ShutdownPaused 测试退出后恢复时，暂停的活动仍保持暂停。
+func TestActivityPool_ShutdownPaused(t *testing.T) {
+	registry := newMemoryActivityRegistry()
+	setupWorker(t)
+	Activities.SetRegistry(registry)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil, WithInterval(1)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	assert.Nil(t, activity.Pause())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results := Activities.Shutdown(ctx)
+	assert.Len(t, results, 1)
+	assert.True(t, results[0].Drained)
+	assert.Equal(t, ActivityStateStopped, results[0].State)
+	record, _ := registry.get(1)
+	assert.True(t, record.Running)
+	assert.True(t, record.Paused, "The pause should be kept for restoring.")
+
+	Activities = InitActivityPool()
+	Activities.SetRegistry(registry)
+	count, err := Activities.Restore(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	activity, err = Activities.GetActivity(1)
+	assert.Nil(t, err)
+	assert.True(t, activity.IsPaused(), "The activity paused before shutting down should be paused again after restoring.")
+
+	assert.Nil(t, activity.Resume())
+	record, _ = registry.get(1)
+	assert.False(t, record.Paused)
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+}
+
+// TestActivityPool_ShutdownIdle 测试退出时，空闲或暂停的工作协程无需等待间隔结束即可退出。
+func TestActivityPool_ShutdownIdle(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil, WithInterval(60000)))
+	assert.Nil(t, Activities.New(2, nil, WithInterval(60000)))
+	for _, id := range []uint64{1, 2} {
+		activity, _ := Activities.GetActivity(id)
+		assert.Nil(t, activity.Start(context.Background()))
+	}
+	activity, _ := Activities.GetActivity(2)
+	assert.Nil(t, activity.Pause())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results := Activities.Shutdown(ctx)
+	assert.Len(t, results, 2)
+	for _, result := range results {
+		assert.True(t, result.Drained, "The idle worker should exit without waiting for the interval.")
+		assert.Equal(t, ActivityStateStopped, result.State)
+	}
+}
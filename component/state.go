@@ -0,0 +1,168 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ActivityState represents the lifecycle state of an activity.
+type ActivityState string
+
+const (
+	ActivityStateCreated  ActivityState = "created"  // The activity has never been started.
+	ActivityStateRunning  ActivityState = "running"  // The worker is processing applications.
+	ActivityStatePaused   ActivityState = "paused"   // The worker is alive, but does not process applications.
+	ActivityStateDraining ActivityState = "draining" // The worker has been asked to stop, and is finishing its current batch.
+	ActivityStateStopped  ActivityState = "stopped"  // The worker has been stopped by the operator or the process.
+	ActivityStateFinished ActivityState = "finished" // The activity is over.
+	ActivityStateSoldOut  ActivityState = "sold_out" // All seats have been confirmed.
+	ActivityStateFailed   ActivityState = "failed"   // The worker exited because of an error.
+)
+
+// activityStateTransitions lists the states that each state can be transited to.
+var activityStateTransitions = map[ActivityState][]ActivityState{
+	ActivityStateCreated:  {ActivityStateRunning},
+	ActivityStateRunning:  {ActivityStatePaused, ActivityStateDraining},
+	ActivityStatePaused:   {ActivityStateRunning, ActivityStateDraining},
+	ActivityStateDraining: {ActivityStateStopped, ActivityStateFinished, ActivityStateSoldOut, ActivityStateFailed},
+	ActivityStateStopped:  {ActivityStateRunning},
+	ActivityStateFinished: {ActivityStateRunning},
+	ActivityStateSoldOut:  {ActivityStateRunning},
+	ActivityStateFailed:   {ActivityStateRunning},
+}
+
+// maxActivityStateTransitions is the maximum number of state transitions kept for each activity.
+const maxActivityStateTransitions = 32
+
+var ErrActivityStateTransitionInvalid = errors.New("invalid activity state transition")
+
+// ErrActivityFinished indicates that the activity is over. The activity stopped with it will be finished instead of stopped.
+var ErrActivityFinished = errors.New("activity finished")
+
+// ErrActivitySoldOut indicates that all seats have been confirmed. The activity stopped with it will be sold out.
+var ErrActivitySoldOut = errors.New("activity sold out")
+
+// CanTransitTo determines whether the state can be transited to the target state.
+func (s ActivityState) CanTransitTo(target ActivityState) bool {
+	for _, v := range activityStateTransitions[s] {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWorking determines whether the worker coroutine is alive and not asked to stop in this state.
+func (s ActivityState) IsWorking() bool {
+	return s == ActivityStateRunning || s == ActivityStatePaused
+}
+
+// ActivityStateTransition records a transition of the activity state.
+type ActivityStateTransition struct {
+	From  ActivityState `json:"from"`
+	To    ActivityState `json:"to"`
+	Time  time.Time     `json:"time"`
+	Cause string        `json:"cause,omitempty"` // The error that caused the transition, empty if not specified.
+}
+
+// terminalStateOf returns the state the draining activity will be settled in, according to the stop cause.
+func terminalStateOf(cause error) ActivityState {
+	switch {
+	case cause == nil,
+		errors.Is(cause, ErrWorkerStopped),
+		errors.Is(cause, ErrAllWorkersStopped),
+		errors.Is(cause, ErrActivityToBeRemoved),
+		errors.Is(cause, context.Canceled):
+		return ActivityStateStopped
+	case errors.Is(cause, ErrActivityFinished):
+		return ActivityStateFinished
+	case errors.Is(cause, ErrActivitySoldOut):
+		return ActivityStateSoldOut
+	}
+	return ActivityStateFailed
+}
+
+// State returns the current state of the activity.
+func (c *Activity) State() ActivityState {
+	c.stateRWLock.RLock()
+	defer c.stateRWLock.RUnlock()
+	if len(c.state) == 0 {
+		return ActivityStateCreated
+	}
+	return c.state
+}
+
+// StateTransitions returns the recent state transitions of the activity, the earliest comes first.
+func (c *Activity) StateTransitions() []ActivityStateTransition {
+	c.stateRWLock.RLock()
+	defer c.stateRWLock.RUnlock()
+	transitions := make([]ActivityStateTransition, len(c.stateTransitions))
+	copy(transitions, c.stateTransitions)
+	return transitions
+}
+
+// transit changes the state of the activity to the target state, and records the cause.
+// If the transition is not allowed, an ErrActivityStateTransitionInvalid error will be returned.
+func (c *Activity) transit(target ActivityState, cause error) error {
+	c.stateRWLock.Lock()
+	defer c.stateRWLock.Unlock()
+	current := c.state
+	if len(current) == 0 {
+		current = ActivityStateCreated
+	}
+	if !current.CanTransitTo(target) {
+		return fmt.Errorf("%w: %s -> %s", ErrActivityStateTransitionInvalid, current, target)
+	}
+	transition := ActivityStateTransition{
+		From: current,
+		To:   target,
+		Time: time.Now(),
+	}
+	if cause != nil {
+		transition.Cause = cause.Error()
+	}
+	c.state = target
+	c.stateTransitions = append(c.stateTransitions, transition)
+	if len(c.stateTransitions) > maxActivityStateTransitions {
+		c.stateTransitions = c.stateTransitions[len(c.stateTransitions)-maxActivityStateTransitions:]
+	}
+	return nil
+}
+
+// settle is called by the worker coroutine when it exits, and moves the activity out of the draining state.
+// If the worker exits without being stopped, for example, the parent context is cancelled, it is drained first.
+// The settled state is persisted, so that the scheduler will not start it again after restoring.
+// The worker may be restarted later according to the restart policy, see ActivityRestartPolicy.
+// The cause is recorded as the stop cause, see StopCause().
+func (c *Activity) settle(cause error) {
+	c.recordStopCause(cause)
+	defer c.flush()
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	if c.contextCancelFunc != nil {
+		c.contextCancelFunc(cause)
+		c.contextCancelFunc = nil
+		if err := c.transit(ActivityStateDraining, cause); err != nil {
+			return
+		}
+	}
+	state := terminalStateOf(cause)
+	if err := c.transit(state, cause); err == nil {
+		c.persist()
+		c.supervise(state)
+	}
+}
+
+// restoredStateOf returns the state of the activity restored from the persisted state.
+// The worker is not alive after restoring, so the working states are regarded as stopped.
+func restoredStateOf(state ActivityState) ActivityState {
+	switch state {
+	case "", ActivityStateCreated:
+		return ActivityStateCreated
+	case ActivityStateRunning, ActivityStatePaused, ActivityStateDraining:
+		return ActivityStateStopped
+	}
+	return state
+}
@@ -0,0 +1,58 @@
+package component
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityState_CanTransitTo(t *testing.T) {
+	assert.True(t, ActivityStateCreated.CanTransitTo(ActivityStateRunning))
+	assert.False(t, ActivityStateCreated.CanTransitTo(ActivityStateDraining), "The activity that has never been started cannot be drained.")
+	assert.True(t, ActivityStateRunning.CanTransitTo(ActivityStatePaused))
+	assert.False(t, ActivityStateRunning.CanTransitTo(ActivityStateStopped), "The running activity must be drained before being stopped.")
+	assert.False(t, ActivityStateDraining.CanTransitTo(ActivityStateRunning), "The draining activity cannot be restarted.")
+	assert.True(t, ActivityStateFailed.CanTransitTo(ActivityStateRunning))
+}
+
+func TestTerminalStateOf(t *testing.T) {
+	assert.Equal(t, ActivityStateStopped, terminalStateOf(nil))
+	assert.Equal(t, ActivityStateStopped, terminalStateOf(ErrWorkerStopped))
+	assert.Equal(t, ActivityStateStopped, terminalStateOf(ErrAllWorkersStopped))
+	assert.Equal(t, ActivityStateFinished, terminalStateOf(ErrActivityFinished))
+	assert.Equal(t, ActivityStateSoldOut, terminalStateOf(ErrActivitySoldOut))
+	assert.Equal(t, ActivityStateFailed, terminalStateOf(errors.New("redis: connection refused")))
+}
+
+func TestActivity_Lifecycle(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	activityID := uint64(1)
+	setupWorkerActivity(t, activityID)
+	activity, err := Activities.GetActivity(activityID)
+	assert.Nil(t, err)
+	assert.Equal(t, ActivityStateCreated, activity.State())
+	assert.Empty(t, activity.StateTransitions())
+
+	assert.ErrorIs(t, activity.transit(ActivityStateStopped, nil), ErrActivityStateTransitionInvalid)
+
+	cause := errors.New("redis: i/o timeout")
+	assert.Nil(t, activity.transit(ActivityStateRunning, nil))
+	assert.Nil(t, activity.transit(ActivityStateDraining, cause))
+	assert.False(t, activity.IsWorking(), "The draining activity is not working.")
+	activity.settle(cause)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+
+	transitions := activity.StateTransitions()
+	assert.Len(t, transitions, 3)
+	assert.Equal(t, ActivityStateDraining, transitions[2].From)
+	assert.Equal(t, ActivityStateFailed, transitions[2].To)
+	assert.Equal(t, cause.Error(), transitions[2].Cause)
+	assert.False(t, transitions[2].Time.IsZero())
+
+	status := activity.Status()
+	assert.Equal(t, ActivityStateFailed, status.State)
+	assert.Equal(t, transitions[2], *status.LastTransition)
+}
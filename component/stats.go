@@ -0,0 +1,141 @@
+package component
+
+import (
+	"sync"
+	"time"
+)
+
+// activityHistorySeconds is how long the per-second history of an activity is kept.
+const activityHistorySeconds = 3600
+
+// ActivityCounters accumulates the results of the successful batches.
+type ActivityCounters struct {
+	Batches      uint64 `json:"batches"`       // The number of successful batches.
+	Total        uint64 `json:"total"`         // The number of applications popped.
+	Confirmed    uint64 `json:"confirmed"`     // The number of seats newly confirmed.
+	Skipped      uint64 `json:"skipped"`       // The number of applications whose applicant already has a seat.
+	Missing      uint64 `json:"missing"`       // The number of applications whose applicant does not exist.
+	OverCapacity uint64 `json:"over_capacity"` // The number of applications beyond capacity, either waitlisted or dropped.
+	Waitlisted   uint64 `json:"waitlisted"`    // The number of applicants newly kept in the waitlist.
+	Retried      uint64 `json:"retried"`       // The number of applications due for retry.
+	Delayed      uint64 `json:"delayed"`       // The number of applications whose applicant is missing, delayed for retry.
+	Registered   uint64 `json:"registered"`    // The number of applicants newly registered for the lottery.
+}
+
+// add accumulates the result of a batch.
+func (c *ActivityCounters) add(result *BatchResult) {
+	c.Batches++
+	c.Total += result.Total
+	c.Confirmed += result.Confirmed
+	c.Skipped += result.Skipped
+	c.Missing += result.Missing
+	c.OverCapacity += result.OverCapacity
+	c.Waitlisted += result.Waitlisted
+	c.Retried += result.Retried
+	c.Delayed += result.Delayed
+	c.Registered += result.Registered
+}
+
+// merge accumulates other counters.
+func (c *ActivityCounters) merge(other *ActivityCounters) {
+	c.Batches += other.Batches
+	c.Total += other.Total
+	c.Confirmed += other.Confirmed
+	c.Skipped += other.Skipped
+	c.Missing += other.Missing
+	c.OverCapacity += other.OverCapacity
+	c.Waitlisted += other.Waitlisted
+	c.Retried += other.Retried
+	c.Delayed += other.Delayed
+	c.Registered += other.Registered
+}
+
+// ConfirmationRate returns the ratio of the seats newly confirmed to the applications popped, 0 if nothing popped.
+func (c *ActivityCounters) ConfirmationRate() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Confirmed) / float64(c.Total)
+}
+
+// DuplicateRate returns the ratio of the applications skipped to the applications popped, 0 if nothing popped.
+func (c *ActivityCounters) DuplicateRate() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Skipped) / float64(c.Total)
+}
+
+// ActivityCountersBucket accumulates the results of the batches finished in a period.
+type ActivityCountersBucket struct {
+	Time time.Time `json:"time"` // The beginning of the period.
+	ActivityCounters
+}
+
+// activityStats keeps the counters since the activity was created,
+// and the per-second buckets of the last hour in a ring indexed by the unix time.
+type activityStats struct {
+	lock    sync.RWMutex
+	totals  ActivityCounters
+	buckets *[activityHistorySeconds]ActivityCountersBucket // Allocated on the first batch.
+}
+
+// recordStats accumulates the result of a successful batch finished at the time.
+func (c *Activity) recordStats(tm time.Time, result *BatchResult) {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+	c.stats.totals.add(result)
+	if c.stats.buckets == nil {
+		c.stats.buckets = &[activityHistorySeconds]ActivityCountersBucket{}
+	}
+	second := tm.Truncate(time.Second)
+	bucket := &c.stats.buckets[second.Unix()%activityHistorySeconds]
+	if bucket.Time.After(second) {
+		// The slot has been taken by a newer second, so the batch is too old to be kept in the history.
+		return
+	}
+	if !bucket.Time.Equal(second) {
+		*bucket = ActivityCountersBucket{Time: second}
+	}
+	bucket.add(result)
+}
+
+// Counters returns the counters accumulated since the activity was created, or restored.
+func (c *Activity) Counters() ActivityCounters {
+	c.stats.lock.RLock()
+	defer c.stats.lock.RUnlock()
+	return c.stats.totals
+}
+
+// History returns the counters of the batches finished since the time, which is at most an hour ago,
+// aggregated into the buckets of the resolution, ordered by time.
+// The resolution is rounded down to whole seconds, and at least one second. The buckets without any batch are omitted.
+func (c *Activity) History(since time.Time, resolution time.Duration) []ActivityCountersBucket {
+	resolution = resolution.Truncate(time.Second)
+	if resolution < time.Second {
+		resolution = time.Second
+	}
+	now := time.Now().Truncate(time.Second)
+	if earliest := now.Add(-(activityHistorySeconds - 1) * time.Second); since.Before(earliest) {
+		since = earliest
+	}
+	since = since.Truncate(time.Second)
+	c.stats.lock.RLock()
+	defer c.stats.lock.RUnlock()
+	items := make([]ActivityCountersBucket, 0)
+	if c.stats.buckets == nil {
+		return items
+	}
+	for second := since; !second.After(now); second = second.Add(time.Second) {
+		bucket := &c.stats.buckets[second.Unix()%activityHistorySeconds]
+		if !bucket.Time.Equal(second) {
+			continue
+		}
+		period := second.Truncate(resolution)
+		if len(items) == 0 || !items[len(items)-1].Time.Equal(period) {
+			items = append(items, ActivityCountersBucket{Time: period})
+		}
+		items[len(items)-1].merge(&bucket.ActivityCounters)
+	}
+	return items
+}
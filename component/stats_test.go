@@ -0,0 +1,41 @@
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_History(t *testing.T) {
+	activity := &Activity{ID: 1}
+	assert.Empty(t, activity.History(time.Now().Add(-time.Hour), time.Second))
+
+	now := time.Now().Truncate(time.Minute)
+	if time.Since(now) < 10*time.Second {
+		// Keep all batches in the same minute but not in the future.
+		now = now.Add(-time.Minute)
+	}
+	activity.recordStats(now, &BatchResult{Total: 10, Confirmed: 6, Skipped: 4})
+	activity.recordStats(now.Add(500*time.Millisecond), &BatchResult{Total: 10, Confirmed: 4, Skipped: 2, Missing: 4})
+	activity.recordStats(now.Add(2*time.Second), &BatchResult{Total: 5, Confirmed: 5})
+	activity.recordStats(now.Add(-2*time.Hour), &BatchResult{Total: 1, Skipped: 1})
+
+	counters := activity.Counters()
+	assert.Equal(t, ActivityCounters{Batches: 4, Total: 26, Confirmed: 15, Skipped: 7, Missing: 4}, counters)
+	assert.InDelta(t, 15.0/26, counters.ConfirmationRate(), 1e-9)
+	assert.InDelta(t, 7.0/26, counters.DuplicateRate(), 1e-9)
+	assert.Equal(t, float64(0), (&ActivityCounters{}).ConfirmationRate())
+
+	history := activity.History(now.Add(-time.Hour), time.Second)
+	assert.Equal(t, []ActivityCountersBucket{
+		{Time: now, ActivityCounters: ActivityCounters{Batches: 2, Total: 20, Confirmed: 10, Skipped: 6, Missing: 4}},
+		{Time: now.Add(2 * time.Second), ActivityCounters: ActivityCounters{Batches: 1, Total: 5, Confirmed: 5}},
+	}, history, "The batches older than an hour should not be kept.")
+
+	history = activity.History(now.Add(-time.Hour), time.Minute)
+	assert.Equal(t, []ActivityCountersBucket{
+		{Time: now, ActivityCounters: ActivityCounters{Batches: 3, Total: 25, Confirmed: 15, Skipped: 6, Missing: 4}},
+	}, history)
+	assert.Len(t, activity.History(now.Add(time.Second), 0), 1)
+}
@@ -0,0 +1,202 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+var ErrActivityRestartPolicyInvalid = errors.New("the restart policy must be never, always or on-failure, and the minimum backoff must not be greater than the maximum")
+
+// RestartPolicy specifies when the worker is restarted after it exits unexpectedly.
+type RestartPolicy string
+
+const (
+	// RestartPolicyNever means that the worker is never restarted.
+	RestartPolicyNever RestartPolicy = "never"
+	// RestartPolicyAlways means that the worker is restarted without limit after it fails or sells out,
+	// the latter of which allows the seats released later to be confirmed again.
+	RestartPolicyAlways RestartPolicy = "always"
+	// RestartPolicyOnFailure means that the worker is restarted after it fails, at most ActivityRestartPolicy.MaxAttempts times in a row.
+	RestartPolicyOnFailure RestartPolicy = "on-failure"
+)
+
+// The default bounds of the backoff between restarts, in milliseconds.
+const (
+	defaultRestartBackoffMin = 100
+	defaultRestartBackoffMax = 30000
+)
+
+// ActivityRestartPolicy specifies whether and when the worker is restarted after it exits unexpectedly.
+// The workers stopped on purpose, including being stopped by the API, removed, finished and stopped when exiting, are never restarted.
+//
+// The n-th restart in a row is delayed by BackoffMin * 2^(n-1), bounded by BackoffMax,
+// of which the latter half is randomized to avoid restarting many workers at the same time.
+// The restarts in a row are reset once a batch succeeds.
+type ActivityRestartPolicy struct {
+	Policy      RestartPolicy `json:"policy" yaml:"Policy"`
+	MaxAttempts uint16        `json:"max_attempts,omitempty" yaml:"MaxAttempts,omitempty"` // Only for on-failure, 0 means unlimited.
+	BackoffMin  uint32        `json:"backoff_min,omitempty" yaml:"BackoffMin,omitempty"`   // In milliseconds, 0 means 100.
+	BackoffMax  uint32        `json:"backoff_max,omitempty" yaml:"BackoffMax,omitempty"`   // In milliseconds, 0 means 30000.
+}
+
+// Validate checks the policy and the bounds of the backoff. nil is valid and means never.
+func (p *ActivityRestartPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Policy {
+	case RestartPolicyNever, RestartPolicyAlways, RestartPolicyOnFailure:
+	default:
+		return ErrActivityRestartPolicyInvalid
+	}
+	if p.BackoffMin > 0 && p.BackoffMax > 0 && p.BackoffMin > p.BackoffMax {
+		return ErrActivityRestartPolicyInvalid
+	}
+	return nil
+}
+
+// shouldRestart determines whether the worker settled in the state should be restarted.
+func (p *ActivityRestartPolicy) shouldRestart(state ActivityState) bool {
+	if p == nil {
+		return false
+	}
+	switch p.Policy {
+	case RestartPolicyAlways:
+		return state == ActivityStateFailed || state == ActivityStateSoldOut
+	case RestartPolicyOnFailure:
+		return state == ActivityStateFailed
+	}
+	return false
+}
+
+// exhausted determines whether the restarts in a row have reached the maximum attempts.
+func (p *ActivityRestartPolicy) exhausted(attempts uint16) bool {
+	return p.Policy == RestartPolicyOnFailure && p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// backoff returns the delay before the attempt-th restart in a row, which starts from 1.
+// jitter is a random number in [0, 1), which randomizes the latter half of the delay.
+func (p *ActivityRestartPolicy) backoff(attempt uint16, jitter float64) time.Duration {
+	backoffMin, backoffMax := time.Duration(p.BackoffMin), time.Duration(p.BackoffMax)
+	if backoffMin == 0 {
+		backoffMin = defaultRestartBackoffMin
+	}
+	if backoffMax == 0 {
+		backoffMax = defaultRestartBackoffMax
+	}
+	if backoffMax < backoffMin {
+		backoffMax = backoffMin
+	}
+	delay := backoffMin * time.Millisecond
+	for i := uint16(1); i < attempt && delay < backoffMax*time.Millisecond; i++ {
+		delay *= 2
+	}
+	if delay > backoffMax*time.Millisecond {
+		delay = backoffMax * time.Millisecond
+	}
+	return delay/2 + time.Duration(jitter*float64(delay/2))
+}
+
+// WithRestartPolicy specifies whether and when the worker is restarted after it exits unexpectedly.
+// If not specified, EnvActivity.RestartPolicy is used.
+func WithRestartPolicy(policy *ActivityRestartPolicy) ActivityOption {
+	return func(activity *Activity) {
+		activity.RestartPolicy = nil
+		if policy != nil {
+			copied := *policy
+			activity.RestartPolicy = &copied
+		}
+	}
+}
+
+// defaultActivityRestartPolicy returns a copy of the restart policy configured, nil if not configured.
+func defaultActivityRestartPolicy() *ActivityRestartPolicy {
+	if GlobalEnv != nil && GlobalEnv.Activity != nil && GlobalEnv.Activity.RestartPolicy != nil {
+		policy := *GlobalEnv.Activity.RestartPolicy
+		return &policy
+	}
+	return nil
+}
+
+// getRestartPolicy returns a copy of the restart policy, nil if not specified.
+func (c *Activity) getRestartPolicy() *ActivityRestartPolicy {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	if c.RestartPolicy == nil {
+		return nil
+	}
+	policy := *c.RestartPolicy
+	return &policy
+}
+
+// supervise schedules a restart of the worker that has just settled in the state, according to the restart policy.
+// The caller must hold contextCancelFuncRWLock.
+func (c *Activity) supervise(state ActivityState) {
+	policy := c.getRestartPolicy()
+	if !policy.shouldRestart(state) || c.pool == nil || c.restartTimer != nil {
+		return
+	}
+	if policy.exhausted(c.restartAttempts) {
+		log.Printf("[ActivityID: %d] not restarted, because it has been restarted %d time(s) in a row.\n", c.ID, c.restartAttempts)
+		return
+	}
+	c.restartAttempts++
+	delay := policy.backoff(c.restartAttempts, rand.Float64())
+	next := time.Now().Add(delay)
+	c.nextRestartAt = &next
+	c.restartTimer = time.AfterFunc(delay, c.restart)
+	log.Printf("[ActivityID: %d] will be restarted in %v, attempt %d.\n", c.ID, delay, c.restartAttempts)
+}
+
+// restart is called when the pending restart is due.
+func (c *Activity) restart() {
+	defer c.flush()
+	c.contextCancelFuncRWLock.Lock()
+	defer c.contextCancelFuncRWLock.Unlock()
+	if c.restartTimer == nil {
+		// Cancelled.
+		return
+	}
+	c.restartTimer = nil
+	c.nextRestartAt = nil
+	if err := c.start(context.Background()); err != nil {
+		log.Printf("[ActivityID: %d] failed to restart: %s\n", c.ID, err.Error())
+		return
+	}
+	c.restarts++
+}
+
+// isRestartPending determines whether the worker has exited and is waiting to be restarted.
+func (c *Activity) isRestartPending() bool {
+	c.contextCancelFuncRWLock.RLock()
+	defer c.contextCancelFuncRWLock.RUnlock()
+	return c.restartTimer != nil
+}
+
+// cancelRestart cancels the pending restart, and returns whether there was one.
+// The caller must hold contextCancelFuncRWLock.
+func (c *Activity) cancelRestart() bool {
+	if c.restartTimer == nil {
+		return false
+	}
+	c.restartTimer.Stop()
+	c.restartTimer = nil
+	c.nextRestartAt = nil
+	return true
+}
+
+// healthy resets the restarts in a row after a batch succeeds.
+func (c *Activity) healthy() {
+	c.contextCancelFuncRWLock.RLock()
+	attempts := c.restartAttempts
+	c.contextCancelFuncRWLock.RUnlock()
+	if attempts == 0 {
+		return
+	}
+	c.contextCancelFuncRWLock.Lock()
+	c.restartAttempts = 0
+	c.contextCancelFuncRWLock.Unlock()
+}
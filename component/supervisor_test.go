@@ -0,0 +1,149 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityRestartPolicy_Validate(t *testing.T) {
+	var policy *ActivityRestartPolicy
+	assert.Nil(t, policy.Validate(), "nil means never.")
+	assert.Nil(t, (&ActivityRestartPolicy{Policy: RestartPolicyOnFailure, MaxAttempts: 3}).Validate())
+	assert.ErrorIs(t, (&ActivityRestartPolicy{}).Validate(), ErrActivityRestartPolicyInvalid)
+	assert.ErrorIs(t, (&ActivityRestartPolicy{Policy: "sometimes"}).Validate(), ErrActivityRestartPolicyInvalid)
+	assert.ErrorIs(t, (&ActivityRestartPolicy{Policy: RestartPolicyAlways, BackoffMin: 200, BackoffMax: 100}).Validate(), ErrActivityRestartPolicyInvalid)
+}
+
+func TestActivityRestartPolicy_ShouldRestart(t *testing.T) {
+	var policy *ActivityRestartPolicy
+	assert.False(t, policy.shouldRestart(ActivityStateFailed))
+	policy = &ActivityRestartPolicy{Policy: RestartPolicyNever}
+	assert.False(t, policy.shouldRestart(ActivityStateFailed))
+	policy = &ActivityRestartPolicy{Policy: RestartPolicyOnFailure}
+	assert.True(t, policy.shouldRestart(ActivityStateFailed))
+	assert.False(t, policy.shouldRestart(ActivityStateSoldOut))
+	policy = &ActivityRestartPolicy{Policy: RestartPolicyAlways}
+	assert.True(t, policy.shouldRestart(ActivityStateFailed))
+	assert.True(t, policy.shouldRestart(ActivityStateSoldOut))
+	for _, state := range []ActivityState{ActivityStateStopped, ActivityStateFinished} {
+		assert.False(t, policy.shouldRestart(state), "The worker stopped on purpose should not be restarted.")
+	}
+}
+
+func TestActivityRestartPolicy_Backoff(t *testing.T) {
+	policy := ActivityRestartPolicy{Policy: RestartPolicyAlways, BackoffMin: 100, BackoffMax: 1000}
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(1, 0))
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(2, 0))
+	assert.Equal(t, 600*time.Millisecond, policy.backoff(4, 0.5))
+	assert.Equal(t, 500*time.Millisecond, policy.backoff(5, 0), "The delay should be bounded by the maximum.")
+	assert.Equal(t, 500*time.Millisecond, policy.backoff(1000, 0))
+	assert.Less(t, policy.backoff(1000, 0.999999), 1000*time.Millisecond)
+
+	policy = ActivityRestartPolicy{Policy: RestartPolicyAlways}
+	assert.Equal(t, time.Duration(defaultRestartBackoffMin)*time.Millisecond/2, policy.backoff(1, 0))
+	assert.Equal(t, policy.exhausted(100), false, "The always policy should never be exhausted.")
+	policy = ActivityRestartPolicy{Policy: RestartPolicyOnFailure, MaxAttempts: 2}
+	assert.False(t, policy.exhausted(1))
+	assert.True(t, policy.exhausted(2))
+}
+
+// TestWorker_Restart 测试工作协程失败后按重启策略重启。
+func TestWorker_Restart(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	var failures atomic.Int64
+	RegisterProcessor("flaky", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		if failures.Add(1) <= 2 {
+			return nil, errors.New("timeout")
+		}
+		return &BatchResult{}, nil
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "flaky")
+		processorsRWLock.Unlock()
+	}()
+
+	policy := ActivityRestartPolicy{Policy: RestartPolicyOnFailure, MaxAttempts: 2, BackoffMin: 10, BackoffMax: 10}
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("flaky"), WithInterval(1), WithRestartPolicy(&policy)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, ActivityStateRunning, activity.State(), "The worker should be running again after 2 failures.")
+	status := activity.Status()
+	assert.Equal(t, uint64(2), status.Restarts)
+	assert.Nil(t, status.NextRestartAt)
+	activity.contextCancelFuncRWLock.RLock()
+	assert.Equal(t, uint16(0), activity.restartAttempts, "A successful batch should reset the restarts in a row.")
+	activity.contextCancelFuncRWLock.RUnlock()
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+}
+
+// TestWorker_RestartExhausted 测试连续重启次数用尽后不再重启，以及停止可取消待重启。
+func TestWorker_RestartExhausted(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	RegisterProcessor("broken", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		return nil, errors.New("broken")
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "broken")
+		processorsRWLock.Unlock()
+	}()
+
+	policy := ActivityRestartPolicy{Policy: RestartPolicyOnFailure, MaxAttempts: 1, BackoffMin: 10, BackoffMax: 10}
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("broken"), WithInterval(1), WithRestartPolicy(&policy)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+	assert.Equal(t, uint64(1), activity.Status().Restarts)
+	assert.Nil(t, activity.Status().NextRestartAt)
+
+	policy = ActivityRestartPolicy{Policy: RestartPolicyAlways, BackoffMin: 60000, BackoffMax: 60000}
+	assert.Nil(t, activity.Update(&ActivitySettings{RestartPolicy: &policy}))
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+	assert.NotNil(t, activity.Status().NextRestartAt, "The restart should be pending.")
+	assert.Nil(t, activity.Stop(ErrWorkerStopped), "Stopping should cancel the pending restart.")
+	assert.Nil(t, activity.Status().NextRestartAt)
+	assert.ErrorIs(t, activity.Stop(ErrWorkerStopped), ErrWorkerHasBeenStopped)
+}
+
+// TestWorker_RestartAfterSchedule 测试到达结束时间时取消待重启，活动不会在窗口结束后重新启动。
+func TestWorker_RestartAfterSchedule(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	RegisterProcessor("broken", ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		return nil, errors.New("broken")
+	}))
+	defer func() {
+		processorsRWLock.Lock()
+		delete(processors, "broken")
+		processorsRWLock.Unlock()
+	}()
+
+	endAt := time.Now().Add(time.Hour)
+	policy := ActivityRestartPolicy{Policy: RestartPolicyAlways, BackoffMin: 60000, BackoffMax: 60000}
+	assert.Nil(t, Activities.New(1, nil, WithProcessor("broken"), WithInterval(1), WithRestartPolicy(&policy), WithSchedule(nil, &endAt)))
+	activity, _ := Activities.GetActivity(1)
+	assert.Nil(t, activity.Start(context.Background()))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+	assert.True(t, activity.isRestartPending())
+
+	Activities.tick(endAt)
+	assert.False(t, activity.isRestartPending(), "The pending restart should be cancelled once the end time is reached.")
+	assert.Nil(t, activity.Status().NextRestartAt)
+	assert.Equal(t, ActivityStateFailed, activity.State())
+}
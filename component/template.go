@@ -0,0 +1,294 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var Templates *ActivityTemplatePool
+
+var ErrActivityTemplateNotExist = errors.New("activity template not exist")
+var ErrActivityTemplateExisted = errors.New("activity template existed")
+var ErrActivityTemplateNameInvalid = errors.New("the name of activity template can only contain letters, digits, '-' and '_'")
+
+var activityTemplateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ActivityTemplate represents the common settings of recurring activities.
+// The zero value of each setting means that the default one is used.
+type ActivityTemplate struct {
+	Name             string                           `json:"name"`
+	Description      string                           `json:"description,omitempty"`
+	Labels           map[string]string                `json:"labels,omitempty"`
+	RedisServerIndex uint8                            `json:"redis_server_index"`
+	Batch            uint16                           `json:"batch,omitempty"`
+	Interval         uint16                           `json:"interval,omitempty"`
+	Mode             ActivityMode                     `json:"mode,omitempty"`
+	AdaptiveBatch    *ActivityAdaptiveBatch           `json:"adaptive_batch,omitempty"`
+	RestartPolicy    *ActivityRestartPolicy           `json:"restart_policy,omitempty"`
+	Retry            *ActivityRetry                   `json:"retry,omitempty"`
+	Partitions       []ActivityPartition              `json:"partitions,omitempty"`
+	Capacity         uint64                           `json:"capacity,omitempty"`
+	Weight           uint16                           `json:"weight,omitempty"`
+	Waitlist         bool                             `json:"waitlist,omitempty"`
+	Quota            uint16                           `json:"quota,omitempty"`
+	Allocation       ActivityAllocation               `json:"allocation,omitempty"`
+	KeyPrefix        *EnvActivityRedisServerKeyPrefix `json:"key_prefix,omitempty"`
+	Processor        string                           `json:"processor,omitempty"`
+}
+
+// Validate checks the name and the settings of the template.
+func (t *ActivityTemplate) Validate() error {
+	if !activityTemplateNamePattern.MatchString(t.Name) {
+		return ErrActivityTemplateNameInvalid
+	}
+	if len(t.Mode) > 0 {
+		if err := t.Mode.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := t.AdaptiveBatch.Validate(); err != nil {
+		return err
+	}
+	if err := t.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := t.Retry.Validate(); err != nil {
+		return err
+	}
+	if t.Retry.Enabled() && len(t.Processor) > 0 && t.Processor != ProcessorFunction {
+		return ErrActivityRetryInvalid
+	}
+	if len(t.Partitions) > maxActivityPartitions {
+		return ErrActivityPartitionsInvalid
+	}
+	if t.Quota > 1 {
+		if len(t.Processor) > 0 && t.Processor != ProcessorFunction {
+			return ErrActivityQuotaInvalid
+		}
+		for _, partition := range t.Partitions {
+			if partition.RedisServerIndex != t.RedisServerIndex {
+				return ErrActivityQuotaInvalid
+			}
+		}
+	}
+	if len(t.Processor) > 0 {
+		if _, err := GetProcessor(t.Processor); err != nil {
+			return err
+		}
+	}
+	if len(t.Allocation) > 0 {
+		if err := t.Allocation.Validate(); err != nil {
+			return err
+		}
+	}
+	if t.Allocation == ActivityAllocationLottery {
+		if t.Quota > 1 || (len(t.Processor) > 0 && t.Processor != ProcessorFunction) {
+			return ErrActivityLotteryInvalid
+		}
+		for _, partition := range t.Partitions {
+			if partition.RedisServerIndex != t.RedisServerIndex {
+				return ErrActivityLotteryInvalid
+			}
+		}
+	}
+	return nil
+}
+
+// Options returns the activity options corresponding to the template.
+// The metadata records the template name in the label "template".
+func (t *ActivityTemplate) Options() []ActivityOption {
+	labels := make(map[string]string, len(t.Labels)+1)
+	for k, v := range t.Labels {
+		labels[k] = v
+	}
+	labels["template"] = t.Name
+	return []ActivityOption{
+		WithBatch(t.Batch),
+		WithInterval(t.Interval),
+		WithMode(t.Mode),
+		WithAdaptiveBatch(t.AdaptiveBatch),
+		WithRestartPolicy(t.RestartPolicy),
+		WithRetry(t.Retry),
+		WithPartitions(t.Partitions),
+		WithCapacity(t.Capacity),
+		WithWeight(t.Weight),
+		WithWaitlist(t.Waitlist),
+		WithQuota(t.Quota),
+		WithAllocation(t.Allocation),
+		WithKeyPrefix(t.KeyPrefix),
+		WithProcessor(t.Processor),
+		WithMetadata(ActivityMetadata{Description: t.Description, Labels: labels}),
+	}
+}
+
+// ActivityTemplateRegistry persists activity templates.
+type ActivityTemplateRegistry interface {
+	// SaveTemplate creates or replaces the template with the same name.
+	SaveTemplate(ctx context.Context, template *ActivityTemplate) error
+	// DeleteTemplate removes the template of the specified name. Deleting a non-existent template is not an error.
+	DeleteTemplate(ctx context.Context, name string) error
+	// LoadTemplates returns all templates.
+	LoadTemplates(ctx context.Context) ([]ActivityTemplate, error)
+}
+
+func (r *RedisActivityRegistry) SaveTemplate(ctx context.Context, template *ActivityTemplate) error {
+	value, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	return client.HSet(ctx, r.TemplateKey, template.Name, value).Err()
+}
+
+func (r *RedisActivityRegistry) DeleteTemplate(ctx context.Context, name string) error {
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	return client.HDel(ctx, r.TemplateKey, name).Err()
+}
+
+func (r *RedisActivityRegistry) LoadTemplates(ctx context.Context) ([]ActivityTemplate, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&r.RedisServerIndex)
+	values, err := client.HGetAll(ctx, r.TemplateKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]ActivityTemplate, 0, len(values))
+	for field, value := range values {
+		var template ActivityTemplate
+		if err := json.Unmarshal([]byte(value), &template); err != nil {
+			log.Printf("[Registry] template %s skipped: %s\n", field, err.Error())
+			continue
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// ActivityTemplatePool represents a pool for activity templates.
+type ActivityTemplatePool struct {
+	Templates       map[string]*ActivityTemplate // Save all templates, the key is the template name.
+	TemplatesRWLock sync.RWMutex                 // A lock to access the template pool.
+	registry        ActivityTemplateRegistry     // The registry that templates are written through to, nil if not persisted.
+}
+
+// InitActivityTemplatePool initialize a template pool.
+func InitActivityTemplatePool() *ActivityTemplatePool {
+	return &ActivityTemplatePool{
+		Templates: make(map[string]*ActivityTemplate),
+	}
+}
+
+// SetRegistry specifies the registry that the template pool writes through to.
+func (p *ActivityTemplatePool) SetRegistry(registry ActivityTemplateRegistry) {
+	p.TemplatesRWLock.Lock()
+	defer p.TemplatesRWLock.Unlock()
+	p.registry = registry
+}
+
+// Restore loads all templates from the registry, and returns the number of them.
+func (p *ActivityTemplatePool) Restore(ctx context.Context) (int, error) {
+	p.TemplatesRWLock.Lock()
+	defer p.TemplatesRWLock.Unlock()
+	if p.registry == nil {
+		return 0, nil
+	}
+	templates, err := p.registry.LoadTemplates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range templates {
+		p.Templates[templates[i].Name] = &templates[i]
+	}
+	return len(templates), nil
+}
+
+// Get returns a copy of the template with the specified name.
+// If the template does not exist, an ErrActivityTemplateNotExist error will be returned.
+func (p *ActivityTemplatePool) Get(name string) (*ActivityTemplate, error) {
+	p.TemplatesRWLock.RLock()
+	defer p.TemplatesRWLock.RUnlock()
+	template, existed := p.Templates[name]
+	if !existed {
+		return nil, ErrActivityTemplateNotExist
+	}
+	copied := *template
+	return &copied, nil
+}
+
+// List returns copies of all templates, ordered by name.
+func (p *ActivityTemplatePool) List() []ActivityTemplate {
+	p.TemplatesRWLock.RLock()
+	defer p.TemplatesRWLock.RUnlock()
+	templates := make([]ActivityTemplate, 0, len(p.Templates))
+	for _, v := range p.Templates {
+		templates = append(templates, *v)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// Add creates a template.
+// If the template with the same name exists, an ErrActivityTemplateExisted error will be returned.
+func (p *ActivityTemplatePool) Add(template *ActivityTemplate) error {
+	return p.set(template, false)
+}
+
+// Replace replaces the template with the same name.
+// If the template does not exist, an ErrActivityTemplateNotExist error will be returned.
+func (p *ActivityTemplatePool) Replace(template *ActivityTemplate) error {
+	return p.set(template, true)
+}
+
+func (p *ActivityTemplatePool) set(template *ActivityTemplate, existing bool) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	p.TemplatesRWLock.Lock()
+	defer p.TemplatesRWLock.Unlock()
+	if _, existed := p.Templates[template.Name]; existed != existing {
+		if existed {
+			return ErrActivityTemplateExisted
+		}
+		return ErrActivityTemplateNotExist
+	}
+	copied := *template
+	if p.registry != nil {
+		if err := p.registry.SaveTemplate(context.Background(), &copied); err != nil {
+			return err
+		}
+	}
+	p.Templates[template.Name] = &copied
+	return nil
+}
+
+// Remove removes the template with the specified name. The activities created from it are not affected.
+// If the template does not exist, an ErrActivityTemplateNotExist error will be returned.
+func (p *ActivityTemplatePool) Remove(name string) error {
+	p.TemplatesRWLock.Lock()
+	defer p.TemplatesRWLock.Unlock()
+	if _, existed := p.Templates[name]; !existed {
+		return ErrActivityTemplateNotExist
+	}
+	if p.registry != nil {
+		if err := p.registry.DeleteTemplate(context.Background(), name); err != nil {
+			return err
+		}
+	}
+	delete(p.Templates, name)
+	return nil
+}
+
+// NewFromTemplate creates an activity with the settings of the template, see New().
+// The options are applied after the template, so they can override the settings of the template.
+func (a *ActivityPool) NewFromTemplate(id uint64, template *ActivityTemplate, options ...ActivityOption) error {
+	index := template.RedisServerIndex
+	return a.New(id, &index, append(template.Options(), options...)...)
+}
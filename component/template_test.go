@@ -0,0 +1,66 @@
+package component
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityTemplatePool(t *testing.T) {
+	Templates = InitActivityTemplatePool()
+	defer func() { Templates = nil }()
+
+	assert.ErrorIs(t, Templates.Add(&ActivityTemplate{Name: "weekly drop"}), ErrActivityTemplateNameInvalid)
+	assert.ErrorIs(t, Templates.Add(&ActivityTemplate{}), ErrActivityTemplateNameInvalid)
+
+	template := ActivityTemplate{Name: "weekly-drop", Batch: 50, Capacity: 100}
+	assert.Nil(t, Templates.Add(&template))
+	assert.ErrorIs(t, Templates.Add(&template), ErrActivityTemplateExisted)
+	assert.ErrorIs(t, Templates.Replace(&ActivityTemplate{Name: "flash"}), ErrActivityTemplateNotExist)
+
+	template.Capacity = 200
+	got, err := Templates.Get("weekly-drop")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), got.Capacity, "The template in the pool should not be affected by the caller.")
+
+	assert.Nil(t, Templates.Replace(&template))
+	got, _ = Templates.Get("weekly-drop")
+	assert.Equal(t, uint64(200), got.Capacity)
+
+	assert.Nil(t, Templates.Add(&ActivityTemplate{Name: "flash"}))
+	templates := Templates.List()
+	assert.Len(t, templates, 2)
+	assert.Equal(t, "flash", templates[0].Name, "The templates should be ordered by name.")
+
+	assert.Nil(t, Templates.Remove("flash"))
+	assert.ErrorIs(t, Templates.Remove("flash"), ErrActivityTemplateNotExist)
+	_, err = Templates.Get("flash")
+	assert.ErrorIs(t, err, ErrActivityTemplateNotExist)
+}
+
+func TestActivityPool_NewFromTemplate(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	template := ActivityTemplate{
+		Name:             "weekly-drop",
+		Description:      "Weekly drop",
+		Labels:           map[string]string{"kind": "weekly"},
+		RedisServerIndex: 1,
+		Batch:            50,
+		Capacity:         100,
+		KeyPrefix:        &EnvActivityRedisServerKeyPrefix{Seat: "weekly_seat_"},
+	}
+	assert.Nil(t, Activities.NewFromTemplate(1, &template, WithCreatedBy("alice")))
+	activity, err := Activities.GetActivity(1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(1), activity.RedisServerIndex)
+	assert.Equal(t, uint16(50), activity.GetBatch())
+	assert.Equal(t, defaultActivityInterval(), activity.Interval, "The unspecified setting should be the default one.")
+	assert.Equal(t, uint64(100), activity.Capacity)
+	assert.Equal(t, "weekly_seat_1", activity.GetRedisServerSeatKeyName())
+	assert.Equal(t, "weekly-drop", activity.Metadata.Labels["template"])
+	assert.Equal(t, "weekly", activity.Metadata.Labels["kind"])
+	assert.Equal(t, "alice", activity.Metadata.CreatedBy)
+	assert.NotContains(t, template.Labels, "template", "The labels of the template should not be modified.")
+}
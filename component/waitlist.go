@@ -0,0 +1,97 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
+)
+
+var ErrApplicantNotWaitlisted = errors.New("the applicant is not in the waitlist")
+
+// ActivityWaitlistEntry represents an applicant in the waitlist.
+type ActivityWaitlistEntry struct {
+	Applicant string    `json:"applicant"`
+	Position  int64     `json:"position"` // Starting from 1.
+	Time      time.Time `json:"time"`     // When the application was popped, which ranks the waitlist like the seats.
+}
+
+// WithWaitlist specifies whether the applicants over capacity are kept in the waitlist instead of being dropped.
+//
+// The waitlist is a sorted set scored by the time when the application was popped, like the seats.
+// If the waitlist is enabled, the worker keeps popping applications into the waitlist after selling out,
+// instead of being stopped with ErrActivitySoldOut.
+func WithWaitlist(waitlist bool) ActivityOption {
+	return func(activity *Activity) {
+		activity.Waitlist = waitlist
+	}
+}
+
+// IsWaitlistEnabled determines whether the applicants over capacity are kept in the waitlist.
+func (c *Activity) IsWaitlistEnabled() bool {
+	c.settingsRWLock.RLock()
+	defer c.settingsRWLock.RUnlock()
+	return c.Waitlist
+}
+
+func (c *Activity) GetRedisServerWaitlistKeyName() string {
+	return fmt.Sprintf("%s%d", c.GetKeyPrefix().Waitlist, c.ID)
+}
+
+// newActivityWaitlistEntry converts the member of the waitlist with its rank.
+func newActivityWaitlistEntry(z redis.Z, rank int64) ActivityWaitlistEntry {
+	applicant, _ := z.Member.(string)
+	return ActivityWaitlistEntry{
+		Applicant: applicant,
+		Position:  rank + 1,
+		Time:      time.UnixMicro(int64(z.Score)),
+	}
+}
+
+// GetWaitlist returns the applicants in the waitlist from the offset, at most limit of them, and the length of the waitlist.
+// If limit is not greater than 0, all applicants from the offset are returned.
+func (c *Activity) GetWaitlist(ctx context.Context, offset, limit int64) ([]ActivityWaitlistEntry, int64, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	key := c.GetRedisServerWaitlistKeyName()
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	members, err := client.ZRangeWithScores(ctx, key, offset, stop).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := client.ZCard(ctx, key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := make([]ActivityWaitlistEntry, len(members))
+	for i, z := range members {
+		entries[i] = newActivityWaitlistEntry(z, offset+int64(i))
+	}
+	return entries, total, nil
+}
+
+// GetWaitlistPosition returns the position of the applicant in the waitlist.
+// If the applicant is not in the waitlist, an ErrApplicantNotWaitlisted error will be returned.
+func (c *Activity) GetWaitlistPosition(ctx context.Context, applicant string) (*ActivityWaitlistEntry, error) {
+	client := environment.GlobalRedisClientPool.GetClient(&c.RedisServerIndex)
+	key := c.GetRedisServerWaitlistKeyName()
+	rank, err := client.ZRank(ctx, key, applicant).Result()
+	if err == redis.Nil {
+		return nil, ErrApplicantNotWaitlisted
+	} else if err != nil {
+		return nil, err
+	}
+	score, err := client.ZScore(ctx, key, applicant).Result()
+	if err == redis.Nil {
+		return nil, ErrApplicantNotWaitlisted
+	} else if err != nil {
+		return nil, err
+	}
+	entry := newActivityWaitlistEntry(redis.Z{Score: score, Member: applicant}, rank)
+	return &entry, nil
+}
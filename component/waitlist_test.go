@@ -0,0 +1,30 @@
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity_Waitlist(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	assert.Nil(t, Activities.New(1, nil, WithCapacity(10), WithPartitions(NewActivityPartitions([]uint8{0, 1}))))
+	activity, _ := Activities.GetActivity(1)
+	assert.Equal(t, "activity_waitlist_1", activity.GetRedisServerWaitlistKeyName())
+	assert.Contains(t, activity.GetRedisServerDataKeyNames(), activity.GetRedisServerWaitlistKeyName())
+	assert.False(t, activity.Status().Waitlist)
+	assert.Empty(t, activity.newBatchTask(0).WaitlistKey, "The applicants over capacity should be dropped if the waitlist is disabled.")
+
+	waitlist := true
+	assert.Nil(t, activity.Update(&ActivitySettings{Waitlist: &waitlist}))
+	assert.True(t, activity.Status().Waitlist)
+	assert.True(t, activity.record().Waitlist)
+	assert.Equal(t, activity.GetRedisServerWaitlistKeyName(), activity.newBatchTask(0).WaitlistKey)
+	assert.Empty(t, activity.newBatchTask(1).WaitlistKey, "The remote partition should leave the waitlist to merging.")
+
+	activity.recordStats(time.Now(), &BatchResult{Total: 3, OverCapacity: 3, Waitlisted: 2})
+	assert.Equal(t, uint64(2), activity.Counters().Waitlisted)
+}
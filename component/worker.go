@@ -2,13 +2,19 @@ package component
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rhosocial/go-rush-common/component/environment"
 )
 
-var processFuncDefault = func(ctx context.Context, activityID uint64) {
-	log.Printf("[ActivityID: %d] working (with no action(s))...\n", activityID)
-}
+var processorDefault = ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+	log.Printf("[ActivityID: %d] working (with no action(s))...\n", task.Activity.ID)
+	return &BatchResult{}, nil
+})
 
 // 默认结束后方法输出指定 activityID 工作结束日志。
 // 建议：停止原因 cause 传入 nil 或 ErrWorkerStopped 都视为正常停止。
@@ -26,44 +32,195 @@ var doneFuncDefault = func(ctx context.Context, activityID uint64, cause error)
 
 // 异常结束后句柄。
 // 如果为 activity 不存在，则恢复最近的错误继续向上传递。
-// 除此之外，如果有错误，则停止活动工作协程。
-var deferredWorkerHandlerFunc = func(activity *Activity) {
+// 除此之外，如果有错误，则停止活动工作协程，并将活动状态置为失败。
+func deferredWorkerHandlerFunc(activity *Activity) {
 	if activity == nil {
 		log.Println(recover())
 	} else if err := recover(); err != nil {
 		log.Println(err)
-		if err = activity.Stop(err.(error)); err != nil {
+		cause, ok := err.(error)
+		if !ok {
+			cause = fmt.Errorf("%v", err)
+		}
+		activity.recordError(cause, activity.Batches())
+		if err := activity.Stop(cause); err != nil {
+			log.Println(err)
+		}
+		activity.settle(cause)
+	}
+}
+
+// 分区协程异常结束后句柄。
+// 记录错误并以该错误停止活动，其余分区协程随之退出，活动状态由 worker 在全部分区协程退出后确定。
+func deferredPartitionHandlerFunc(activity *Activity, partition int) {
+	if err := recover(); err != nil {
+		log.Printf("[ActivityID: %d, Partition: %d] %v\n", activity.ID, partition, err)
+		cause, ok := err.(error)
+		if !ok {
+			cause = fmt.Errorf("%v", err)
+		}
+		activity.recordError(cause, activity.Batches())
+		if err := activity.Stop(cause); err != nil {
 			log.Println(err)
 		}
 	}
 }
 
 // worker 处理活动。
-// 指定 activityID 的活动必须存在，否则将报错。
-// process 为处理方法，可以为 nil。如果为 nil，则采用 processFuncDefault。
+// activity 不能为 nil，否则将报错。
+// 每个分区由独立的协程处理，详见 consume()。全部分区协程退出后，再以上下文的停止原因确定活动状态。
+// processor 为处理器，可以为 nil。如果为 nil，则采用 processorDefault。
 // done 为处理结束后方法，可以为 nil。如果为 nil，则采用 doneFuncDefault。
-func worker(ctx context.Context, interval uint16, activityID uint64, process func(context.Context, uint64), done func(context.Context, uint64, error)) {
-	if process == nil {
-		process = processFuncDefault
+func worker(ctx context.Context, activity *Activity, processor Processor, done func(context.Context, uint64, error)) {
+	if processor == nil {
+		processor = processorDefault
 	}
 	if done == nil {
 		done = doneFuncDefault
 	}
 
-	activity, err := Activities.GetActivity(activityID)
 	defer deferredWorkerHandlerFunc(activity)
-	if err != nil {
-		panic(err)
+	if activity == nil {
+		panic(ErrActivityNotExist)
 	}
 
+	var wg sync.WaitGroup
+	for i := 0; i < activity.partitionCount(); i++ {
+		wg.Add(1)
+		go func(partition int) {
+			defer wg.Done()
+			defer deferredPartitionHandlerFunc(activity, partition)
+			consume(ctx, activity, partition, processor)
+		}(i)
+	}
+	wg.Wait()
+	done(ctx, activity.ID, context.Cause(ctx))
+	activity.settle(context.Cause(ctx))
+}
+
+// consume 处理一个分区，直至上下文结束。
+// 轮询模式下，每次处理前等待活动当前的间隔时间，因此修改间隔后在下一次处理时生效。
+// 阻塞模式下，每次处理前阻塞至分区的申请队列有数据，上一批满载时则不等待，连续处理直至积压清空，详见 Activity.wait()。
+// 活动暂停期间，协程保持运行，但跳过处理。
+// 处理器返回错误时，以该错误停止活动，活动状态将置为失败。全部席位确认后，以 ErrActivitySoldOut 停止活动，
+// 但启用候补名单时继续处理，超出容量的申请人列入候补名单，详见 WithWaitlist()。
+func consume(ctx context.Context, activity *Activity, partition int, processor Processor) {
+	backlog := false
 	for {
-		time.Sleep(time.Duration(interval) * time.Millisecond)
+		if !backlog {
+			activity.wait(ctx, partition)
+		}
 		select {
 		case <-ctx.Done():
-			done(ctx, activityID, context.Cause(ctx))
 			return
 		default:
-			process(ctx, activityID)
+			if activity.IsPaused() {
+				backlog = false
+				continue
+			}
+			backlog = process(ctx, activity, partition, processor) && activity.GetMode() == ActivityModeBlock
 		}
 	}
 }
+
+// process 处理分区的一批，并根据结果决定是否停止活动。返回值表示本批是否满载，即队列中可能仍有积压。
+// 处理前须取得所在 redis 服务器的限流器许可，详见 redisServerLimiter。等待许可期间活动被停止，则放弃本批。
+// 处理器使用独立的上下文，因此停止活动时当前批次仍会完成，而不会中途中断。
+// 如果处理期间活动已被停止，则忽略处理器返回的错误。
+// 位于其它 redis 服务器的分区，处理后将暂存的席位合并至活动的席位，详见 Activity.mergeSeats()。
+// 演练模式下不调用处理器，而是模拟本批，结果只计入演练报告，详见 WithDryRun()。模拟售罄也只记入演练报告，而不停止活动。
+func process(ctx context.Context, activity *Activity, partition int, processor Processor) bool {
+	task := activity.newBatchTask(partition)
+	release, err := activity.acquire(ctx, task.RedisServerIndex)
+	if err != nil {
+		return false
+	}
+	batch := activity.batches.Add(1)
+	dryRun := activity.IsDryRun()
+	var result *BatchResult
+	if dryRun {
+		result, err = activity.simulate(context.Background(), task)
+	} else {
+		result, err = processor.Process(context.Background(), task)
+	}
+	release()
+	if err == nil && !dryRun && activity.isRemotePartition(partition) {
+		if release, err = activity.acquire(ctx, activity.RedisServerIndex); err == nil {
+			err = activity.mergeSeats(context.Background(), partition, result)
+			release()
+		}
+	}
+	if err != nil {
+		if !dryRun {
+			activity.recordPartition(partition, nil)
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("[ActivityID: %d, Partition: %d]: %s\n", activity.ID, partition, err.Error())
+		activity.recordError(err, batch)
+		if err := activity.Stop(err); err != nil {
+			log.Println(err)
+		}
+		return false
+	}
+	mark := ""
+	if dryRun {
+		mark = " [dry-run]"
+	} else {
+		activity.recordPartition(partition, result)
+		activity.recordStats(time.Now(), result)
+	}
+	elapsed := result.Elapsed
+	if elapsed > time.Minute {
+		elapsed = elapsed.Truncate(time.Second)
+	}
+	log.Printf("[ActivityID: %d, Partition: %d]%s: %d application(s): %d seat(s) newly confirmed, %d skipped, %d applicant(s) missing, %d over capacity, %d waitlisted, %d retried, %d delayed, %d registered, time elapsed : %13v.\n",
+		activity.ID, partition, mark, result.Total, result.Confirmed, result.Skipped, result.Missing, result.OverCapacity, result.Waitlisted,
+		result.Retried, result.Delayed, result.Registered, elapsed)
+	activity.adapt(task.Batch, result)
+	activity.healthy()
+	if result.SoldOut && !dryRun && !activity.IsWaitlistEnabled() {
+		log.Printf("[ActivityID: %d]: sold out.\n", activity.ID)
+		if err := activity.Stop(ErrActivitySoldOut); err != nil && err != ErrWorkerHasBeenStopped {
+			log.Println(err)
+		}
+		return false
+	}
+	return result.Total >= uint64(task.Batch)
+}
+
+// sleep 等待指定的时间，上下文结束时立即返回。
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// wait 等待分区的下一批。
+// 轮询模式或暂停期间，等待活动当前的间隔时间。
+// 阻塞模式下，以 BLMOVE 将分区申请队列的队首移回队首，即不改变队列的前提下阻塞至队列有数据，最长阻塞活动当前的间隔时间，但不少于一秒。
+// 因此空闲活动每个间隔只占用一次阻塞命令，而不会执行处理。阻塞失败时，等待活动当前的间隔时间后再处理。
+// 上下文结束时立即返回，因此停止空闲或暂停的活动时无需等待间隔结束。
+func (c *Activity) wait(ctx context.Context, partition int) {
+	interval := c.GetInterval()
+	if c.GetMode() != ActivityModeBlock || c.IsPaused() {
+		sleep(ctx, interval)
+		return
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	key := c.GetRedisServerPartitionApplicationKeyName(partition)
+	index := c.partitionRedisServerIndex(partition)
+	client := environment.GlobalRedisClientPool.GetClient(&index)
+	err := client.BLMove(ctx, key, key, "LEFT", "LEFT", interval).Err()
+	if err != nil && err != redis.Nil && ctx.Err() == nil {
+		log.Printf("[ActivityID: %d, Partition: %d] failed to block: %s\n", c.ID, partition, err.Error())
+		c.recordError(err, c.Batches())
+		sleep(ctx, c.GetInterval())
+	}
+}
@@ -2,6 +2,7 @@ package component
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -9,6 +10,10 @@ import (
 )
 
 func setupWorker(t *testing.T) {
+	// The worker needs the key prefixes to prepare batches.
+	if err := LoadEnvDefault(); err != nil {
+		t.Error(err)
+	}
 	Activities = InitActivityPool()
 	if Activities == nil {
 		t.Error("Activity Pool should not be nil.")
@@ -68,9 +73,12 @@ func TestWorker_ActivityExists(t *testing.T) {
 
 		ctxChild, cancel := context.WithCancelCause(context.Background())
 		activity.contextCancelFunc = cancel
+		assert.Nil(t, activity.transit(ActivityStateRunning, nil), "The newly added activity should be able to run.")
 		activity.contextCancelFuncRWLock.Unlock()
 
-		go worker(ctxChild, 1, activity.ID, nil, nil)
+		interval := uint16(1)
+		assert.Nil(t, activity.Update(&ActivitySettings{Interval: &interval}))
+		go worker(ctxChild, activity, nil, nil)
 
 		assert.True(t, activity.IsWorking(), "The activity should be working.")
 		time.Sleep(time.Microsecond * 10) // With an interval of 1 millisecond, it should output about 10 times after a 10 millisecond pause.
@@ -84,3 +92,48 @@ func TestWorker_ActivityExists(t *testing.T) {
 
 	//
 }
+
+// TestWorker_Paused 测试暂停期间工作协程保持运行但不处理。
+func TestWorker_Paused(t *testing.T) {
+	setupWorker(t)
+	defer teardownWorker(t)
+
+	activityID := uint64(time.Now().UnixNano())
+	setupWorkerActivity(t, activityID)
+	defer teardownWorkerActivity(t, activityID)
+
+	activity, err := Activities.GetActivity(activityID)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, activity.Pause(), ErrWorkerIsNotWorking, "The activity that has never been started cannot be paused.")
+
+	var processed atomic.Int64
+	processor := ProcessorFunc(func(ctx context.Context, task *BatchTask) (*BatchResult, error) {
+		processed.Add(1)
+		return &BatchResult{}, nil
+	})
+	ctxChild, cancel := context.WithCancelCause(context.Background())
+	activity.contextCancelFuncRWLock.Lock()
+	activity.contextCancelFunc = cancel
+	activity.contextCancelFuncRWLock.Unlock()
+	assert.Nil(t, activity.transit(ActivityStateRunning, nil))
+	interval := uint16(1)
+	assert.Nil(t, activity.Update(&ActivitySettings{Interval: &interval}))
+	go worker(ctxChild, activity, processor, nil)
+
+	time.Sleep(time.Millisecond * 20)
+	assert.Nil(t, activity.Pause())
+	assert.ErrorIs(t, activity.Pause(), ErrWorkerIsPaused)
+	assert.True(t, activity.IsWorking(), "The paused activity is still working.")
+	time.Sleep(time.Millisecond * 5) // Wait for the batch in progress.
+	paused := processed.Load()
+	assert.Greater(t, paused, int64(0))
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, paused, processed.Load(), "The paused worker should not process.")
+
+	assert.Nil(t, activity.Resume())
+	assert.ErrorIs(t, activity.Resume(), ErrWorkerIsNotPaused)
+	time.Sleep(time.Millisecond * 20)
+	assert.Greater(t, processed.Load(), paused, "The resumed worker should process again.")
+
+	assert.Nil(t, activity.Stop(ErrWorkerStopped))
+}
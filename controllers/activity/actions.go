@@ -1,8 +1,10 @@
 package controllerActivity
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -15,6 +17,12 @@ type ActivityBody struct {
 	ActivityID uint64 `form:"activity_id" validate:"required" json:"activity_id" binding:"required"`
 }
 
+type ActionStatusResponseData struct {
+	component.ActivityStatus
+	StateTransitions []component.ActivityStateTransition `json:"state_transitions"`
+	Errors           []component.ActivityError           `json:"errors"`
+}
+
 func (a *ControllerActivity) ActionStatus(c *gin.Context) {
 	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
 	if err != nil {
@@ -26,12 +34,546 @@ func (a *ControllerActivity) ActionStatus(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
 		return
 	}
-	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", !activity.IsWorking(), nil))
+	data := ActionStatusResponseData{
+		ActivityStatus:   activity.Status(),
+		StateTransitions: activity.StateTransitions(),
+		Errors:           activity.Errors(),
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+type ActionErrorsResponseData struct {
+	Errors    []component.ActivityError    `json:"errors"`               // 最近的错误，最早的在前。
+	StopCause *component.ActivityStopCause `json:"stop_cause,omitempty"` // 工作协程上次退出的原因。
+}
+
+func (a *ControllerActivity) ActionErrors(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	data := ActionErrorsResponseData{
+		Errors:    activity.Errors(),
+		StopCause: activity.StopCause(),
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+func (a *ControllerActivity) ActionPartitions(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	partitions, err := activity.PartitionStatus(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the partitions", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", partitions, nil))
+}
+
+func (a *ControllerActivity) ActionDryRun(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", activity.DryRunReport(), nil))
+}
+
+// ActivityQueryStats 查询统计的范围。
+type ActivityQueryStats struct {
+	Since      uint16 `form:"since" default:"3600"`   // 查询最近多少秒的历史，最多 3600。
+	Resolution uint16 `form:"resolution" default:"1"` // 每个历史桶的时长（秒）。
+}
+
+type ActionStatsResponseData struct {
+	Counters         component.ActivityCounters         `json:"counters"`          // 活动创建以来的累计值。
+	ConfirmationRate float64                            `json:"confirmation_rate"` // 新确认席位数占取出申请数的比例。
+	DuplicateRate    float64                            `json:"duplicate_rate"`    // 已有席位而跳过的申请数占取出申请数的比例。
+	History          []component.ActivityCountersBucket `json:"history"`           // 按时间排序，没有批次的桶将被省略。
+}
+
+func (a *ControllerActivity) ActionStats(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	query := ActivityQueryStats{Since: 3600, Resolution: 1}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "query not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	counters := activity.Counters()
+	data := ActionStatsResponseData{
+		Counters:         counters,
+		ConfirmationRate: counters.ConfirmationRate(),
+		DuplicateRate:    counters.DuplicateRate(),
+		History:          activity.History(time.Now().Add(-time.Duration(query.Since)*time.Second), time.Duration(query.Resolution)*time.Second),
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+// ActivityQueryWaitlist 查询候补名单的范围。
+type ActivityQueryWaitlist struct {
+	Offset int64 `form:"offset,default=0" binding:"min=0"`           // 从第几个候补开始，从 0 开始。
+	Limit  int64 `form:"limit,default=100" binding:"min=1,max=1000"` // 最多返回多少个候补。
+}
+
+type ActionWaitlistResponseData struct {
+	Total      int64                             `json:"total"`      // 候补名单的长度。
+	Applicants []component.ActivityWaitlistEntry `json:"applicants"` // 按候补顺序排列。
+}
+
+func (a *ControllerActivity) ActionWaitlist(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var query ActivityQueryWaitlist
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "query not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	applicants, total, err := activity.GetWaitlist(c, query.Offset, query.Limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the waitlist", err.Error(), nil))
+		return
+	}
+	data := ActionWaitlistResponseData{
+		Total:      total,
+		Applicants: applicants,
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+func (a *ControllerActivity) ActionWaitlistPosition(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	entry, err := activity.GetWaitlistPosition(c, c.Param("applicant"))
+	if errors.Is(err, component.ErrApplicantNotWaitlisted) {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "applicant not waitlisted", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the waitlist", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "applicant waitlisted", entry, nil))
+}
+
+// ActivityBodyRevokeSeat 撤销席位的参数。
+type ActivityBodyRevokeSeat struct {
+	Reason string `form:"reason" json:"reason" binding:"required"` // 撤销原因，如付款失败、欺诈等，将记录在撤销记录中。
+}
+
+func (a *ControllerActivity) ActionRevokeSeat(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var body ActivityBodyRevokeSeat
+	if err := c.ShouldBindWith(&body, binding.Form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "reason not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	events, err := activity.RevokeSeat(c, c.Param("applicant"), body.Reason)
+	if errors.Is(err, component.ErrApplicantNotSeated) {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "applicant not seated", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to revoke the seat", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "seat revoked", events, nil))
+}
+
+func (a *ControllerActivity) ActionApplicantSeats(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	seats, err := activity.GetApplicantSeats(c, c.Param("applicant"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the seats", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", seats, nil))
+}
+
+// ActivityBodyDraw 抽签的参数。
+type ActivityBodyDraw struct {
+	Seed string `form:"seed" json:"seed"` // 抽签种子，不提供则随机生成。
+}
+
+func (a *ControllerActivity) ActionDraw(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var body ActivityBodyDraw
+	if err := c.ShouldBindWith(&body, binding.Form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "seed not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	draw, err := activity.Draw(c, body.Seed)
+	if errors.Is(err, component.ErrActivityNotLottery) || errors.Is(err, component.ErrActivityAlreadyDrawn) ||
+		errors.Is(err, component.ErrWorkerIsWorking) || errors.Is(err, component.ErrWorkerIsDraining) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to draw", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to draw", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "lottery drawn", draw, nil))
+}
+
+type ActionLotteryResponseData struct {
+	Registered int64                   `json:"registered"`     // 已登记的申请人数。
+	Draw       *component.ActivityDraw `json:"draw,omitempty"` // 抽签记录，尚未抽签时省略。
+}
+
+func (a *ControllerActivity) ActionLottery(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	if !activity.IsLottery() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not lottery", component.ErrActivityNotLottery.Error(), nil))
+		return
+	}
+	var data ActionLotteryResponseData
+	if data.Registered, err = activity.CountRegistrations(c); err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the lottery", err.Error(), nil))
+		return
+	}
+	if data.Draw, err = activity.GetDraw(c); err != nil && !errors.Is(err, component.ErrActivityNotDrawn) {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the lottery", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+func (a *ControllerActivity) ActionSeatEvents(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", activity.SeatEvents(), nil))
+}
+
+// ActivityQueryDeadLetters 查询死信队列的范围。
+type ActivityQueryDeadLetters struct {
+	Partition int   `form:"partition,default=0" binding:"min=0"`        // 分区序号，未分区的活动为 0。
+	Offset    int64 `form:"offset,default=0" binding:"min=0"`           // 从第几个申请开始，从 0 开始。
+	Limit     int64 `form:"limit,default=100" binding:"min=1,max=1000"` // 最多返回多少个申请。
+}
+
+type ActionDeadLettersResponseData struct {
+	Total        int64                          `json:"total"`        // 死信队列的长度。
+	Applications []component.ActivityDeadLetter `json:"applications"` // 按取出顺序排列。
+}
+
+func (a *ControllerActivity) ActionDeadLetters(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var query ActivityQueryDeadLetters
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "query not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	applications, total, err := activity.GetDeadLetters(c, query.Partition, query.Offset, query.Limit)
+	if errors.Is(err, component.ErrActivityPartitionNotExist) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "partition not valid", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to read the dead letters", err.Error(), nil))
+		return
+	}
+	data := ActionDeadLettersResponseData{
+		Total:        total,
+		Applications: applications,
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity existed", data, nil))
+}
+
+// ActivityBodyRequeueDeadLetters 将死信重新入队的参数。
+type ActivityBodyRequeueDeadLetters struct {
+	Partition int   `form:"partition,default=0" json:"partition" binding:"min=0"` // 分区序号，未分区的活动为 0。
+	Count     int64 `form:"count,default=0" json:"count" binding:"min=0"`         // 最早的多少个申请重新入队，0 表示全部。
+}
+
+func (a *ControllerActivity) ActionRequeueDeadLetters(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var body ActivityBodyRequeueDeadLetters
+	if err := c.ShouldBindWith(&body, binding.Form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "body not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	count, err := activity.RequeueDeadLetters(c, body.Partition, body.Count)
+	if errors.Is(err, component.ErrActivityPartitionNotExist) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "partition not valid", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to requeue the dead letters", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "dead letters requeued", count, nil))
+}
+
+func (a *ControllerActivity) ActionPurgeDeadLetters(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var query ActivityQueryDeadLetters
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "query not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	count, err := activity.PurgeDeadLetters(c, query.Partition)
+	if errors.Is(err, component.ErrActivityPartitionNotExist) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "partition not valid", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to purge the dead letters", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "dead letters purged", count, nil))
+}
+
+// ActivityBodyAdaptiveBatch 自适应批量参数。
+type ActivityBodyAdaptiveBatch struct {
+	AdaptiveBudget *uint16 `form:"adaptive_budget" json:"adaptive_budget"` // 每批的时间预算（毫秒），0 表示固定批量。不提供时，创建按配置参数，修改则不修改。
+	AdaptiveMin    uint16  `form:"adaptive_min" json:"adaptive_min"`       // 最小批量，预算大于 0 时必须大于 0。
+	AdaptiveMax    uint16  `form:"adaptive_max" json:"adaptive_max"`       // 最大批量，预算大于 0 时不能小于最小批量。
+}
+
+// AdaptiveBatch 将请求转换为自适应批量参数。如果未提供预算，则返回 nil。
+func (b *ActivityBodyAdaptiveBatch) AdaptiveBatch() *component.ActivityAdaptiveBatch {
+	if b.AdaptiveBudget == nil {
+		return nil
+	}
+	return &component.ActivityAdaptiveBatch{
+		Budget: *b.AdaptiveBudget,
+		Min:    b.AdaptiveMin,
+		Max:    b.AdaptiveMax,
+	}
+}
+
+// ActivityBodyRestartPolicy 重启策略参数。
+type ActivityBodyRestartPolicy struct {
+	RestartPolicy      *string `form:"restart_policy" json:"restart_policy"`             // never、always 或 on-failure。不提供时，创建按配置参数，修改则不修改。
+	RestartMaxAttempts uint16  `form:"restart_max_attempts" json:"restart_max_attempts"` // on-failure 时连续重启的最大次数，0 表示不限。
+	RestartBackoffMin  uint32  `form:"restart_backoff_min" json:"restart_backoff_min"`   // 重启前最短等待时间（毫秒），0 表示默认值。
+	RestartBackoffMax  uint32  `form:"restart_backoff_max" json:"restart_backoff_max"`   // 重启前最长等待时间（毫秒），0 表示默认值。
+}
+
+// Policy 将请求转换为重启策略。如果未提供策略，则返回 nil。
+func (b *ActivityBodyRestartPolicy) Policy() *component.ActivityRestartPolicy {
+	if b.RestartPolicy == nil {
+		return nil
+	}
+	return &component.ActivityRestartPolicy{
+		Policy:      component.RestartPolicy(*b.RestartPolicy),
+		MaxAttempts: b.RestartMaxAttempts,
+		BackoffMin:  b.RestartBackoffMin,
+		BackoffMax:  b.RestartBackoffMax,
+	}
+}
+
+// ActivityBodyRetry 申请人缺失时的重试参数。
+type ActivityBodyRetry struct {
+	RetryAttempts *uint16 `form:"retry_attempts" json:"retry_attempts"` // 每个申请的最大重试次数，0 表示不重试。不提供时，创建按配置参数，修改则不修改。
+	RetryDelay    uint32  `form:"retry_delay" json:"retry_delay"`       // 每次重试前的等待时间（毫秒），重试次数大于 0 时必须大于 0。
+}
+
+// Retry 将请求转换为重试参数。如果未提供重试次数，则返回 nil。
+func (b *ActivityBodyRetry) Retry() *component.ActivityRetry {
+	if b.RetryAttempts == nil {
+		return nil
+	}
+	return &component.ActivityRetry{
+		Attempts: *b.RetryAttempts,
+		Delay:    b.RetryDelay,
+	}
 }
 
 type ActivityBodyAdd struct {
 	ActivityBody
-	RedisServerIndex *uint8 `form:"redis_server_index" json:"redis_server_index" default:"0"` // 指针表示可以不提供，不提供时按默认值default。
+	ActivityBodyAdaptiveBatch
+	ActivityBodyRestartPolicy
+	ActivityBodyRetry
+	RedisServerIndex *uint8     `form:"redis_server_index" json:"redis_server_index" default:"0"` // 指针表示可以不提供，不提供时按默认值default。
+	Capacity         *uint64    `form:"capacity" json:"capacity" default:"0"`                     // 席位数量，0 表示不限。
+	Interval         *uint16    `form:"interval" json:"interval"`                                 // 处理间隔（毫秒），不提供时按配置参数。
+	Batch            *uint16    `form:"batch" json:"batch"`                                       // 每批处理的申请数，不提供时按配置参数。
+	Processor        string     `form:"processor" json:"processor"`                               // 处理器名称，如 function、transaction、naive，不提供时按配置参数。
+	Mode             string     `form:"mode" json:"mode"`                                         // 等待申请的方式，poll 或 block，不提供时按配置参数。
+	Partitions       []uint8    `form:"partitions" json:"partitions"`                             // 各分区所在的 redis 服务器序号，长度即分区数，不提供则不分区。
+	Weight           *uint16    `form:"weight" json:"weight"`                                     // 与其它活动争用同一 redis 服务器时的权重，不提供时按配置参数。
+	DryRun           bool       `form:"dry_run" json:"dry_run"`                                   // 是否为演练模式，演练时只读取申请而不修改任何数据。
+	Waitlist         bool       `form:"waitlist" json:"waitlist"`                                 // 是否将超出容量的申请人列入候补名单，否则丢弃。
+	Quota            *uint16    `form:"quota" json:"quota"`                                       // 每个申请人最多可获得的席位数，不提供时按配置参数。
+	Allocation       string     `form:"allocation" json:"allocation"`                             // 席位分配方式，fifo 或 lottery，不提供时为 fifo。
+	StartAt          *time.Time `form:"start_at" json:"start_at"`                                 // 自动开始时间，RFC3339 格式，不提供则不自动开始。
+	EndAt            *time.Time `form:"end_at" json:"end_at"`                                     // 自动结束时间，RFC3339 格式，不提供则不自动结束。
+	Name             string     `form:"name" json:"name"`
+	Description      string     `form:"description" json:"description"`
+	Labels           []string   `form:"labels" json:"labels"` // 标签，每个均为 key=value 形式。
+	CreatedBy        string     `form:"created_by" json:"created_by"`
+}
+
+// Options 将请求中提供的可选参数转换为活动选项。
+// 如果标签格式不正确，则报 component.ErrActivityLabelInvalid 错误。
+func (b *ActivityBodyAdd) Options() ([]component.ActivityOption, error) {
+	labels, err := component.ParseActivityLabels(b.Labels)
+	if err != nil {
+		return nil, err
+	}
+	options := []component.ActivityOption{
+		component.WithMetadata(component.ActivityMetadata{
+			Name:        b.Name,
+			Description: b.Description,
+			Labels:      labels,
+			CreatedBy:   b.CreatedBy,
+		}),
+	}
+	if b.Capacity != nil {
+		options = append(options, component.WithCapacity(*b.Capacity))
+	}
+	if b.Interval != nil {
+		options = append(options, component.WithInterval(*b.Interval))
+	}
+	if b.Batch != nil {
+		options = append(options, component.WithBatch(*b.Batch))
+	}
+	if len(b.Processor) > 0 {
+		options = append(options, component.WithProcessor(b.Processor))
+	}
+	if len(b.Mode) > 0 {
+		options = append(options, component.WithMode(component.ActivityMode(b.Mode)))
+	}
+	if adaptive := b.AdaptiveBatch(); adaptive != nil {
+		options = append(options, component.WithAdaptiveBatch(adaptive))
+	}
+	if policy := b.Policy(); policy != nil {
+		options = append(options, component.WithRestartPolicy(policy))
+	}
+	if retry := b.Retry(); retry != nil {
+		options = append(options, component.WithRetry(retry))
+	}
+	if b.Weight != nil {
+		options = append(options, component.WithWeight(*b.Weight))
+	}
+	if b.DryRun {
+		options = append(options, component.WithDryRun(true))
+	}
+	if b.Waitlist {
+		options = append(options, component.WithWaitlist(true))
+	}
+	if b.Quota != nil {
+		options = append(options, component.WithQuota(*b.Quota))
+	}
+	if len(b.Allocation) > 0 {
+		options = append(options, component.WithAllocation(component.ActivityAllocation(b.Allocation)))
+	}
+	if len(b.Partitions) > 0 {
+		options = append(options, component.WithPartitions(component.NewActivityPartitions(b.Partitions)))
+	}
+	if b.StartAt != nil || b.EndAt != nil {
+		options = append(options, component.WithSchedule(b.StartAt, b.EndAt))
+	}
+	return options, nil
 }
 
 func (a *ControllerActivity) ActionStart(c *gin.Context) {
@@ -72,6 +614,49 @@ func (a *ControllerActivity) ActionStop(c *gin.Context) {
 	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "the worker stopped", nil, nil))
 }
 
+func (a *ControllerActivity) ActionPause(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	err = activity.Pause()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to pause a worker", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "the worker paused", nil, nil))
+}
+
+func (a *ControllerActivity) ActionResume(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	err = activity.Resume()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, a.NewResponseGeneric(c, 1, "failed to resume a worker", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "the worker resumed", nil, nil))
+}
+
+// ActivityQueryAdd 指定创建活动时使用的模板。
+type ActivityQueryAdd struct {
+	Template string `form:"template"` // 模板名称。指定时仅采用请求中的活动 ID、开始和结束时间以及创建人，其余均按模板。
+}
+
 func (a *ControllerActivity) ActionAdd(c *gin.Context) {
 	var body ActivityBodyAdd
 	err := c.ShouldBindWith(&body, binding.FormPost)
@@ -79,7 +664,39 @@ func (a *ControllerActivity) ActionAdd(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
 		return
 	}
-	err = component.Activities.New(body.ActivityID, body.RedisServerIndex)
+	var query ActivityQueryAdd
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "template not valid", err.Error(), nil))
+		return
+	}
+	if len(query.Template) > 0 {
+		a.actionAddFromTemplate(c, &body, query.Template)
+		return
+	}
+	options, err := body.Options()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	err = component.Activities.New(body.ActivityID, body.RedisServerIndex, options...)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to add new activity", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity added", nil, nil))
+}
+
+func (a *ControllerActivity) actionAddFromTemplate(c *gin.Context, body *ActivityBodyAdd, name string) {
+	template, err := component.Templates.Get(name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "template not found", err.Error(), nil))
+		return
+	}
+	options := []component.ActivityOption{component.WithCreatedBy(body.CreatedBy)}
+	if body.StartAt != nil || body.EndAt != nil {
+		options = append(options, component.WithSchedule(body.StartAt, body.EndAt))
+	}
+	err = component.Activities.NewFromTemplate(body.ActivityID, template, options...)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to add new activity", err.Error(), nil))
 		return
@@ -87,11 +704,69 @@ func (a *ControllerActivity) ActionAdd(c *gin.Context) {
 	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity added", nil, nil))
 }
 
+type ActivityBodyUpdate struct {
+	ActivityBodyAdaptiveBatch
+	ActivityBodyRestartPolicy
+	ActivityBodyRetry
+	Interval *uint16 `form:"interval" json:"interval"` // 处理间隔（毫秒），不提供则不修改。
+	Batch    *uint16 `form:"batch" json:"batch"`       // 每批处理的申请数，不提供则不修改。
+	Mode     *string `form:"mode" json:"mode"`         // 等待申请的方式，poll 或 block，不提供则不修改。
+	Weight   *uint16 `form:"weight" json:"weight"`     // 与其它活动争用同一 redis 服务器时的权重，不提供则不修改。
+	DryRun   *bool   `form:"dry_run" json:"dry_run"`   // 是否为演练模式，不提供则不修改。切换时丢弃演练状态。
+	Waitlist *bool   `form:"waitlist" json:"waitlist"` // 是否将超出容量的申请人列入候补名单，不提供则不修改。
+	Quota    *uint16 `form:"quota" json:"quota"`       // 每个申请人最多可获得的席位数，不提供则不修改。
+}
+
+func (a *ControllerActivity) ActionUpdate(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "activity not valid", err.Error(), nil))
+		return
+	}
+	var body ActivityBodyUpdate
+	if err := c.ShouldBindWith(&body, binding.FormPost); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "settings not valid", err.Error(), nil))
+		return
+	}
+	activity, err := component.Activities.GetActivity(activityID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
+		return
+	}
+	settings := component.ActivitySettings{
+		Interval:      body.Interval,
+		Batch:         body.Batch,
+		AdaptiveBatch: body.AdaptiveBatch(),
+		RestartPolicy: body.Policy(),
+		Retry:         body.Retry(),
+		Weight:        body.Weight,
+		DryRun:        body.DryRun,
+		Waitlist:      body.Waitlist,
+		Quota:         body.Quota,
+	}
+	if body.Mode != nil {
+		mode := component.ActivityMode(*body.Mode)
+		settings.Mode = &mode
+	}
+	err = activity.Update(&settings)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to update the activity", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity updated", activity.Status(), nil))
+}
+
 type ActivityBodyDelete struct {
 	ActivityBody
 	StopBeforeRemoving bool `form:"stop_before_removing" json:"stop_before_removing,omitempty" default:"false"`
 }
 
+// ActivityQueryPurge 删除活动时是否同时清除 redis 中的数据。
+type ActivityQueryPurge struct {
+	Purge   bool `form:"purge" default:"false"`   // 是否清除申请、申请人和席位数据。
+	Archive bool `form:"archive" default:"false"` // 清除前是否归档席位。
+}
+
 func (a *ControllerActivity) ActionDelete(c *gin.Context) {
 	activityID, err := strconv.ParseUint(c.Param("activityID"), 10, 64)
 	if err != nil {
@@ -102,7 +777,19 @@ func (a *ControllerActivity) ActionDelete(c *gin.Context) {
 	if err != nil {
 		stopBeforeRemoving = false
 	}
-	err = component.Activities.Remove(activityID, stopBeforeRemoving)
+	var query ActivityQueryPurge
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "purge options not valid", err.Error(), nil))
+		return
+	}
+	var result *component.ActivityPurgeResult
+	if query.Purge {
+		result, err = component.Activities.RemoveAndPurge(context.Background(), activityID, stopBeforeRemoving, &component.ActivityPurgeOptions{
+			Archive: query.Archive,
+		})
+	} else {
+		err = component.Activities.Remove(activityID, stopBeforeRemoving)
+	}
 	if err == component.ErrActivityNotExist {
 		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "activity not found", err.Error(), nil))
 		return
@@ -110,7 +797,52 @@ func (a *ControllerActivity) ActionDelete(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to remove the activity", err.Error(), nil))
 		return
 	}
-	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity removed", nil, nil))
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "activity removed", result, nil))
+}
+
+// ActivityQueryList 列出活动时的筛选条件和分页参数。
+type ActivityQueryList struct {
+	Labels           []string `form:"label"` // 标签，每个均为 key=value 形式，需全部满足。
+	State            string   `form:"state"`
+	RedisServerIndex *uint8   `form:"redis_server_index"`
+	Page             int      `form:"page,default=1" binding:"min=1"`
+	PageSize         int      `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+type ActionListResponseData struct {
+	Total int                        `json:"total"`
+	Page  int                        `json:"page"`
+	Items []component.ActivityStatus `json:"items"`
+}
+
+func (a *ControllerActivity) ActionList(c *gin.Context) {
+	var query ActivityQueryList
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "filter not valid", err.Error(), nil))
+		return
+	}
+	labels, err := component.ParseActivityLabels(query.Labels)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "filter not valid", err.Error(), nil))
+		return
+	}
+	items, total := component.Activities.List(&component.ActivityFilter{
+		Labels:           labels,
+		State:            component.ActivityState(query.State),
+		RedisServerIndex: query.RedisServerIndex,
+		Offset:           (query.Page - 1) * query.PageSize,
+		Limit:            query.PageSize,
+	})
+	data := ActionListResponseData{
+		Total: total,
+		Page:  query.Page,
+		Items: items,
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "success", data, nil))
+}
+
+func (a *ControllerActivity) ActionSchedule(c *gin.Context) {
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "success", component.Activities.Schedule(time.Now()), nil))
 }
 
 func (a *ControllerActivity) ActionStopAll(c *gin.Context) {
@@ -125,12 +857,31 @@ type ControllerActivity struct {
 func (a *ControllerActivity) RegisterActions(r *gin.Engine) {
 	controller := r.Group("/activity")
 	{
+		controller.GET("", a.ActionList)
 		controller.PUT("", a.ActionAdd)
+		controller.GET("/schedule", a.ActionSchedule)
 		controller.DELETE("/:activityID", a.ActionDelete)
 		controller.DELETE("/:activityID/:stopBeforeRemoving", a.ActionDelete)
+		controller.DELETE("/:activityID/seats/:applicant", a.ActionRevokeSeat)
+		controller.DELETE("/:activityID/dead-letters", a.ActionPurgeDeadLetters)
 		controller.GET("/:activityID", a.ActionStatus)
+		controller.PATCH("/:activityID", a.ActionUpdate)
+		controller.GET("/:activityID/errors", a.ActionErrors)
+		controller.GET("/:activityID/partitions", a.ActionPartitions)
+		controller.GET("/:activityID/stats", a.ActionStats)
+		controller.GET("/:activityID/dry-run", a.ActionDryRun)
+		controller.GET("/:activityID/waitlist", a.ActionWaitlist)
+		controller.GET("/:activityID/waitlist/:applicant", a.ActionWaitlistPosition)
+		controller.GET("/:activityID/seat-events", a.ActionSeatEvents)
+		controller.GET("/:activityID/seats/:applicant", a.ActionApplicantSeats)
+		controller.GET("/:activityID/dead-letters", a.ActionDeadLetters)
+		controller.POST("/:activityID/dead-letters/requeue", a.ActionRequeueDeadLetters)
+		controller.GET("/:activityID/lottery", a.ActionLottery)
+		controller.POST("/:activityID/lottery/draw", a.ActionDraw)
 		controller.POST("/:activityID/start", a.ActionStart)
 		controller.POST("/:activityID/stop", a.ActionStop)
+		controller.POST("/:activityID/pause", a.ActionPause)
+		controller.POST("/:activityID/resume", a.ActionResume)
 		controller.POST("/stop-all", a.ActionStopAll)
 	}
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/rhosocial/go-rush-common/component/controller"
 	"github.com/rhosocial/go-rush-common/component/environment"
 	"github.com/rhosocial/go-rush-common/component/redis"
@@ -13,8 +15,9 @@ import (
 )
 
 type ActionStatusResponseData struct {
-	RedisServers map[uint8]RedisServerStatus         `json:"redis_servers"`
-	Activities   map[uint64]component.ActivityStatus `json:"activities"`
+	RedisServers map[uint8]RedisServerStatus          `json:"redis_servers"`
+	Limiters     []component.RedisServerLimiterStatus `json:"limiters"` // 已使用的 redis 服务器限流器。
+	Activities   map[uint64]component.ActivityStatus  `json:"activities"`
 }
 
 type RedisServerStatus struct {
@@ -43,6 +46,7 @@ func (a *ControllerServer) ActionStatus(c *gin.Context) {
 	}
 	data := ActionStatusResponseData{
 		RedisServers: status,
+		Limiters:     component.RedisServerLimiters(),
 		Activities:   component.Activities.Status(),
 	}
 	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "success", data, nil))
@@ -56,6 +60,30 @@ func (a *ControllerServer) ActionRedisServerFunctionLoadReplace(c *gin.Context)
 	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, fmt.Sprintf("%d byte(s) uploaded", code.Size), nil, nil))
 }
 
+// RedisServerLimitBody redis 服务器的限流参数，0 表示不限。
+type RedisServerLimitBody struct {
+	MaxConcurrent uint16 `form:"max_concurrent" json:"max_concurrent"` // 同时处理的最大批次数。
+	MaxPerSecond  uint16 `form:"max_per_second" json:"max_per_second"` // 每秒开始处理的最大批次数。
+}
+
+func (a *ControllerServer) ActionRedisServerLimit(c *gin.Context) {
+	index, err := strconv.ParseUint(c.Param("index"), 10, 8)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "redis server index not valid", err.Error(), nil))
+		return
+	}
+	var body RedisServerLimitBody
+	if err := c.ShouldBindWith(&body, binding.FormPost); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "limit not valid", err.Error(), nil))
+		return
+	}
+	component.SetRedisServerLimit(uint8(index), component.RedisServerLimit{
+		MaxConcurrent: body.MaxConcurrent,
+		MaxPerSecond:  body.MaxPerSecond,
+	})
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "limit updated", component.RedisServerLimiters(), nil))
+}
+
 type ControllerServer struct {
 	controller.GenericController
 }
@@ -66,6 +94,7 @@ func (a *ControllerServer) RegisterActions(r *gin.Engine) {
 		controller.GET("", a.ActionStatus)
 		controllerRedis := controller.Group("/redis")
 		{
+			controllerRedis.PUT("/:index/limit", a.ActionRedisServerLimit)
 			controllerRedisFunction := controllerRedis.Group("/function")
 			{
 				controllerRedisFunction.POST("/load_replace", a.ActionRedisServerFunctionLoadReplace)
@@ -0,0 +1,191 @@
+package controllerTemplate
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rhosocial/go-rush-common/component/controller"
+	"github.com/rhosocial/go-rush-consumer/component"
+)
+
+type TemplateBody struct {
+	Name                  string   `form:"name" json:"name"` // 模板名称，修改时以路径参数为准。
+	Description           string   `form:"description" json:"description"`
+	Labels                []string `form:"labels" json:"labels"` // 标签，每个均为 key=value 形式。
+	RedisServerIndex      uint8    `form:"redis_server_index" json:"redis_server_index" default:"0"`
+	Batch                 uint16   `form:"batch" json:"batch"`                                   // 每批处理的申请数，0 表示按配置参数。
+	Interval              uint16   `form:"interval" json:"interval"`                             // 处理间隔（毫秒），0 表示按配置参数。
+	Capacity              uint64   `form:"capacity" json:"capacity"`                             // 席位数量，0 表示不限。
+	Weight                uint16   `form:"weight" json:"weight"`                                 // 与其它活动争用同一 redis 服务器时的权重，0 表示按配置参数。
+	Waitlist              bool     `form:"waitlist" json:"waitlist"`                             // 是否将超出容量的申请人列入候补名单。
+	Quota                 uint16   `form:"quota" json:"quota"`                                   // 每个申请人最多可获得的席位数，0 表示按配置参数。
+	Allocation            string   `form:"allocation" json:"allocation"`                         // 席位分配方式，fifo 或 lottery，不提供时为 fifo。
+	Processor             string   `form:"processor" json:"processor"`                           // 处理器名称，不提供时按配置参数。
+	Mode                  string   `form:"mode" json:"mode"`                                     // 等待申请的方式，poll 或 block，不提供时按配置参数。
+	AdaptiveBudget        *uint16  `form:"adaptive_budget" json:"adaptive_budget"`               // 每批的时间预算（毫秒），0 表示固定批量，不提供时按配置参数。
+	AdaptiveMin           uint16   `form:"adaptive_min" json:"adaptive_min"`                     // 最小批量。
+	AdaptiveMax           uint16   `form:"adaptive_max" json:"adaptive_max"`                     // 最大批量。
+	RestartPolicy         string   `form:"restart_policy" json:"restart_policy"`                 // never、always 或 on-failure，不提供时按配置参数。
+	RestartMaxAttempts    uint16   `form:"restart_max_attempts" json:"restart_max_attempts"`     // on-failure 时连续重启的最大次数，0 表示不限。
+	RestartBackoffMin     uint32   `form:"restart_backoff_min" json:"restart_backoff_min"`       // 重启前最短等待时间（毫秒），0 表示默认值。
+	RestartBackoffMax     uint32   `form:"restart_backoff_max" json:"restart_backoff_max"`       // 重启前最长等待时间（毫秒），0 表示默认值。
+	RetryAttempts         *uint16  `form:"retry_attempts" json:"retry_attempts"`                 // 申请人缺失时每个申请的最大重试次数，0 表示不重试，不提供时按配置参数。
+	RetryDelay            uint32   `form:"retry_delay" json:"retry_delay"`                       // 每次重试前的等待时间（毫秒）。
+	Partitions            []uint8  `form:"partitions" json:"partitions"`                         // 各分区所在的 redis 服务器序号，长度即分区数，不提供则不分区。
+	KeyPrefixApplication  string   `form:"key_prefix_application" json:"key_prefix_application"` // 键名前缀，不提供时按配置参数，下同。
+	KeyPrefixApplicant    string   `form:"key_prefix_applicant" json:"key_prefix_applicant"`
+	KeyPrefixSeat         string   `form:"key_prefix_seat" json:"key_prefix_seat"`
+	KeyPrefixSeatArchive  string   `form:"key_prefix_seat_archive" json:"key_prefix_seat_archive"`
+	KeyPrefixWaitlist     string   `form:"key_prefix_waitlist" json:"key_prefix_waitlist"`
+	KeyPrefixRevocation   string   `form:"key_prefix_revocation" json:"key_prefix_revocation"`
+	KeyPrefixDeadLetter   string   `form:"key_prefix_dead_letter" json:"key_prefix_dead_letter"`
+	KeyPrefixRetry        string   `form:"key_prefix_retry" json:"key_prefix_retry"`
+	KeyPrefixSeatCount    string   `form:"key_prefix_seat_count" json:"key_prefix_seat_count"`
+	KeyPrefixRegistration string   `form:"key_prefix_registration" json:"key_prefix_registration"`
+	KeyPrefixDraw         string   `form:"key_prefix_draw" json:"key_prefix_draw"`
+}
+
+// Template 将请求转换为活动模板。
+// 如果标签格式不正确，则报 component.ErrActivityLabelInvalid 错误。
+func (b *TemplateBody) Template() (*component.ActivityTemplate, error) {
+	labels, err := component.ParseActivityLabels(b.Labels)
+	if err != nil {
+		return nil, err
+	}
+	template := component.ActivityTemplate{
+		Name:             b.Name,
+		Description:      b.Description,
+		Labels:           labels,
+		RedisServerIndex: b.RedisServerIndex,
+		Batch:            b.Batch,
+		Interval:         b.Interval,
+		Capacity:         b.Capacity,
+		Weight:           b.Weight,
+		Waitlist:         b.Waitlist,
+		Quota:            b.Quota,
+		Allocation:       component.ActivityAllocation(b.Allocation),
+		Processor:        b.Processor,
+		Mode:             component.ActivityMode(b.Mode),
+		Partitions:       component.NewActivityPartitions(b.Partitions),
+	}
+	if b.AdaptiveBudget != nil {
+		template.AdaptiveBatch = &component.ActivityAdaptiveBatch{
+			Budget: *b.AdaptiveBudget,
+			Min:    b.AdaptiveMin,
+			Max:    b.AdaptiveMax,
+		}
+	}
+	if len(b.RestartPolicy) > 0 {
+		template.RestartPolicy = &component.ActivityRestartPolicy{
+			Policy:      component.RestartPolicy(b.RestartPolicy),
+			MaxAttempts: b.RestartMaxAttempts,
+			BackoffMin:  b.RestartBackoffMin,
+			BackoffMax:  b.RestartBackoffMax,
+		}
+	}
+	if b.RetryAttempts != nil {
+		template.Retry = &component.ActivityRetry{
+			Attempts: *b.RetryAttempts,
+			Delay:    b.RetryDelay,
+		}
+	}
+	prefix := component.EnvActivityRedisServerKeyPrefix{
+		Application:  b.KeyPrefixApplication,
+		Applicant:    b.KeyPrefixApplicant,
+		Seat:         b.KeyPrefixSeat,
+		SeatArchive:  b.KeyPrefixSeatArchive,
+		Waitlist:     b.KeyPrefixWaitlist,
+		Revocation:   b.KeyPrefixRevocation,
+		DeadLetter:   b.KeyPrefixDeadLetter,
+		Retry:        b.KeyPrefixRetry,
+		SeatCount:    b.KeyPrefixSeatCount,
+		Registration: b.KeyPrefixRegistration,
+		Draw:         b.KeyPrefixDraw,
+	}
+	if prefix != (component.EnvActivityRedisServerKeyPrefix{}) {
+		template.KeyPrefix = &prefix
+	}
+	return &template, nil
+}
+
+func (a *ControllerTemplate) ActionList(c *gin.Context) {
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "success", component.Templates.List(), nil))
+}
+
+func (a *ControllerTemplate) ActionGet(c *gin.Context) {
+	template, err := component.Templates.Get(c.Param("name"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "template not found", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "template existed", template, nil))
+}
+
+func (a *ControllerTemplate) ActionAdd(c *gin.Context) {
+	var body TemplateBody
+	if err := c.ShouldBindWith(&body, binding.FormPost); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "template not valid", err.Error(), nil))
+		return
+	}
+	template, err := body.Template()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "template not valid", err.Error(), nil))
+		return
+	}
+	if err := component.Templates.Add(template); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to add new template", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "template added", template, nil))
+}
+
+func (a *ControllerTemplate) ActionReplace(c *gin.Context) {
+	var body TemplateBody
+	if err := c.ShouldBindWith(&body, binding.FormPost); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "template not valid", err.Error(), nil))
+		return
+	}
+	body.Name = c.Param("name")
+	template, err := body.Template()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "template not valid", err.Error(), nil))
+		return
+	}
+	err = component.Templates.Replace(template)
+	if err == component.ErrActivityTemplateNotExist {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "template not found", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to replace the template", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "template replaced", template, nil))
+}
+
+func (a *ControllerTemplate) ActionDelete(c *gin.Context) {
+	err := component.Templates.Remove(c.Param("name"))
+	if err == component.ErrActivityTemplateNotExist {
+		c.AbortWithStatusJSON(http.StatusNotFound, a.NewResponseGeneric(c, 1, "template not found", err.Error(), nil))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, a.NewResponseGeneric(c, 1, "failed to remove the template", err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, a.NewResponseGeneric(c, 0, "template removed", nil, nil))
+}
+
+type ControllerTemplate struct {
+	controller.GenericController
+}
+
+func (a *ControllerTemplate) RegisterActions(r *gin.Engine) {
+	controller := r.Group("/template")
+	{
+		controller.GET("", a.ActionList)
+		controller.PUT("", a.ActionAdd)
+		controller.GET("/:name", a.ActionGet)
+		controller.POST("/:name", a.ActionReplace)
+		controller.DELETE("/:name", a.ActionDelete)
+	}
+}
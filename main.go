@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,6 +20,7 @@ import (
 	"github.com/rhosocial/go-rush-consumer/component"
 	controllerActivity "github.com/rhosocial/go-rush-consumer/controllers/activity"
 	"github.com/rhosocial/go-rush-consumer/controllers/server"
+	"github.com/rhosocial/go-rush-consumer/controllers/template"
 )
 
 var r *gin.Engine
@@ -43,32 +47,61 @@ func main() {
 	}
 	// 都加载错误则使用默认值。
 	component.Activities = component.InitActivityPool()
-	if err := initExamples(); err != nil {
+	component.Templates = component.InitActivityTemplatePool()
+	if err := initActivities(); err != nil {
 		println(err.Error())
 		return
 	}
+	stopScheduler := startScheduler()
 	r = gin.New()
 	if !configEngine(r) {
 		return
 	}
-	SetupCloseHandler()
-	if err := r.Run(fmt.Sprintf(":%d", *(*(*component.GlobalEnv).Net).ListenPort)); err != nil {
-		log.Println(err.Error())
-		return
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", *(*(*component.GlobalEnv).Net).ListenPort),
+		Handler: r,
 	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err.Error())
+		}
+	}()
+	waitForSignal()
+	shutdown(srv, stopScheduler)
 }
 
-func initExamples() error {
-	activityID := uint64(time.Now().Unix())
-	if err := component.Activities.New(activityID, nil); err != nil {
+// initActivities 从 redis 中的注册表恢复活动模板和活动，并重新启动之前正在工作的协程。
+// 如果没有配置 redis 服务器，则活动模板和活动仅保存在内存中。
+func initActivities() error {
+	if len(*(*component.GlobalEnv).RedisServers) == 0 {
+		log.Println("No redis servers configured, activities will not be persisted.")
+		return nil
+	}
+	registry := component.NewRedisActivityRegistry((*(*component.GlobalEnv).Activity).Registry)
+	component.Templates.SetRegistry(registry)
+	component.Activities.SetRegistry(registry)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	count, err := component.Templates.Restore(ctx)
+	if err != nil {
 		return err
 	}
-	if _, err := component.Activities.GetActivity(activityID); err != nil {
+	log.Printf("%d template(s) restored.\n", count)
+	count, err = component.Activities.Restore(ctx)
+	if err != nil {
 		return err
 	}
+	log.Printf("%d activity(ies) restored.\n", count)
 	return nil
 }
 
+// startScheduler 启动活动调度器，每秒检查一次各活动的开始和结束时间。返回停止调度器的方法。
+func startScheduler() context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	go component.Activities.RunScheduler(ctx, time.Second)
+	return cancel
+}
+
 func configEngine(r *gin.Engine) bool {
 	r.Use(
 		logger.AppendRequestID(),
@@ -81,20 +114,41 @@ func configEngine(r *gin.Engine) bool {
 	cs.RegisterActions(r)
 	var ca controllerActivity.ControllerActivity
 	ca.RegisterActions(r)
+	var ct controllerTemplate.ControllerTemplate
+	ct.RegisterActions(r)
 	return true
 }
 
-// SetupCloseHandler creates a 'listener' on a new goroutine which will notify the
-// program if it receives an interrupt from the OS. We then handle this by calling
-// our cleaning-up procedure and exiting the program.
-func SetupCloseHandler() {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGKILL)
-	go func() {
-		<-c
-		log.Println("\r- Ctrl+C pressed in Terminal")
-		count := component.Activities.StopAll()
-		log.Printf("%d worker(s) stopped.\n", count)
-		os.Exit(0)
-	}()
+// waitForSignal 阻塞至收到中断或终止信号。
+// SIGKILL 无法被捕获，因此不在监听之列。信号通道需有缓冲，否则在接收前到达的信号将被丢弃。
+func waitForSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	sig := <-c
+	signal.Stop(c)
+	log.Printf("\r- %s received, shutting down...\n", sig)
+}
+
+// shutdown 依次停止接受管理请求、停止调度器、等待各活动的工作协程完成当前批次，最后关闭 redis 客户端。
+// 全部步骤共用 EnvNet.ShutdownTimeout 指定的时限，超时未退出的工作协程将在报告中标出。
+func shutdown(srv *http.Server, stopScheduler context.CancelFunc) {
+	timeout := time.Duration(*(*(*component.GlobalEnv).Net).ShutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down the server: %s\n", err.Error())
+	}
+	stopScheduler()
+	results := component.Activities.Shutdown(ctx)
+	drained := 0
+	for _, v := range results {
+		if v.Drained {
+			drained++
+		}
+		log.Printf("[ActivityID: %d] stopped: %t, drained: %t, state: %s, elapsed: %v.\n", v.ID, v.Stopped, v.Drained, v.State, v.Elapsed)
+	}
+	log.Printf("%d of %d worker(s) drained.\n", drained, len(results))
+	if err := component.CloseRedisClients(); err != nil {
+		log.Printf("Failed to close redis clients: %s\n", err.Error())
+	}
 }